@@ -0,0 +1,29 @@
+package LR
+
+import (
+	"calibration"
+)
+
+// Calibrate fits a calibrator on held out data and keeps it in the model,
+// it replaces any calibrator the model had before.
+func (lr *LogisticRegression) Calibrate(method string, heldOut []SparseTrainItem, bins int) (
+	before, after calibration.Report, err error) {
+	scores := make([]float64, len(heldOut))
+	labels := make([]int, len(heldOut))
+	for i := range heldOut {
+		scores[i] = lr.RawScore(&heldOut[i])
+		labels[i] = heldOut[i].Label
+	}
+	before = calibration.Evaluate(scores, labels, bins)
+
+	calibrator, err := calibration.Fit(method, scores, labels)
+	if err != nil {
+		return
+	}
+	lr.Calibrator = calibrator
+	for i := range scores {
+		scores[i] = calibrator.Calibrate(scores[i])
+	}
+	after = calibration.Evaluate(scores, labels, bins)
+	return
+}
@@ -0,0 +1,46 @@
+package LR
+
+import (
+	"calibration"
+	"config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCalibratorSavedWithModel(t *testing.T) {
+	items := sparseItems(400, 50, 1)
+	lr := &LogisticRegression{}
+	lr.Fit(config.TrainConf{FeatureLen: 50, OneBatch: 10, LearningRate: 0.1}, items[:300], nil, 3)
+	if _, _, err := lr.Calibrate(calibration.Isotonic, items[300:], 10); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "calibrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lr.model")
+	if err := lr.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Calibrator, lr.Calibrator) {
+		t.Fatalf("loaded calibrator %+v, saved %+v", loaded.Calibrator, lr.Calibrator)
+	}
+	for i := range items[300:] {
+		if got, want := loaded.Score(&items[300+i]), lr.Score(&items[300+i]); got != want {
+			t.Fatalf("loaded model scores %v, saved %v", got, want)
+		}
+	}
+
+	NewOnline(loaded, config.TrainConf{LearningRate: 0.1})
+	if loaded.Calibrator != nil {
+		t.Error("online learning keeps the offline calibrator")
+	}
+}
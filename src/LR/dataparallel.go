@@ -0,0 +1,125 @@
+package LR
+
+import (
+	"config"
+	"fmt"
+	"logging"
+	"metrics"
+	"time"
+)
+
+// AllReducer sums a vector over all ranks, every rank gets the same result.
+type AllReducer interface {
+	Rank() int
+	World() int
+	AllReduceSum(data []float64) error
+}
+
+// ShardDense keeps the items of rank when lines are dealt round robin.
+func ShardDense(items []IndexTrainItem, rank, world int) []IndexTrainItem {
+	var result []IndexTrainItem
+	for i := rank; i < len(items); i += world {
+		result = append(result, items[i])
+	}
+	return result
+}
+
+// FitDataParallel trains on the shard of this rank. Every step each rank
+// takes OneBatch/world items, gradients are summed with the reducer and
+// all ranks apply the same update. With the file order and OneBatch
+// divisible by world the weights match Fit on the whole file.
+func (smr *SoftMaxRegression) FitDataParallel(conf config.TrainConf, shard, testing []IndexTrainItem,
+	iter int, comm AllReducer) error {
+	world := comm.World()
+	featureLen, labelCount := InferDims(shard)
+	// every rank needs the dims of the whole data, not of its shard
+	dims := make([]float64, 2*world)
+	dims[2*comm.Rank()] = float64(featureLen)
+	dims[2*comm.Rank()+1] = float64(labelCount)
+	if err := comm.AllReduceSum(dims); err != nil {
+		return err
+	}
+	for rank := 0; rank < world; rank++ {
+		if int(dims[2*rank]) > featureLen {
+			featureLen = int(dims[2*rank])
+		}
+		if int(dims[2*rank+1]) > labelCount {
+			labelCount = int(dims[2*rank+1])
+		}
+	}
+	smr.init(conf, confOr(conf.FeatureLen, featureLen), confOr(conf.LabelCount, labelCount))
+	smr.prepareSparse(shard)
+
+	oneBatch := conf.OneBatch
+	if oneBatch%world != 0 {
+		logging.Warn("onebatch is not divisible by ranks", "onebatch", oneBatch, "ranks", world)
+	}
+	localBatch := oneBatch / world
+	if localBatch == 0 {
+		return fmt.Errorf("onebatch %d smaller than %d ranks", oneBatch, world)
+	}
+
+	// every rank must run the same number of steps
+	total := []float64{float64(len(shard))}
+	if err := comm.AllReduceSum(total); err != nil {
+		return err
+	}
+	steps := int(total[0]) / oneBatch
+
+	labels := make([]int, len(shard))
+	for i, item := range shard {
+		labels[i] = item.Label
+	}
+	shardConf := conf
+	if shardConf.Seed != 0 {
+		shardConf.Seed += int64(comm.Rank())
+	}
+	sampler := newSampler(shardConf, OrderFile, labels)
+
+	learningRate := conf.LearningRate
+	grad := smr.newGradient()
+	for it := 0; it < iter; it++ {
+		iterStart := time.Now()
+		trainLoss := 0.0
+		order := sampler.Epoch(len(shard))
+		batch := make([]IndexTrainItem, 0, localBatch)
+		for step := 0; step < steps; step++ {
+			batch = batch[:0]
+			for _, index := range order[step*localBatch : (step+1)*localBatch] {
+				batch = append(batch, shard[index])
+			}
+			smr.gradient(batch, grad)
+			for bi, item := range batch {
+				trainLoss += metrics.NegLog(smr.softmax[bi][item.Label])
+			}
+			if err := comm.AllReduceSum(grad); err != nil {
+				return err
+			}
+			smr.applyGradient(grad, learningRate/float64(localBatch*world), nil)
+			// the reduced gradient is dense even when the local batch is sparse
+			for i := range grad {
+				grad[i] = 0
+			}
+		}
+
+		// the loss is summed over all ranks, every rank takes part
+		loss := []float64{trainLoss}
+		if err := comm.AllReduceSum(loss); err != nil {
+			return err
+		}
+		trained := steps * oneBatch
+		if trained > 0 {
+			trainLoss = loss[0] / float64(trained)
+		}
+		if comm.Rank() == 0 {
+			result := smr.Evaluate(testing)
+			cost := time.Now().Sub(iterStart)
+			logging.With(result.Fields()...).Info("iter done",
+				"iter", it, "rank", 0, "train_loss", trainLoss, "cost", cost)
+			smr.Recorder.Record(metrics.Record{Epoch: it, Step: (it + 1) * steps, TrainLoss: trainLoss,
+				LearningRate: learningRate, Throughput: float64(trained) / cost.Seconds(),
+				Seconds: cost.Seconds(), Validation: result})
+		}
+	}
+	return nil
+}
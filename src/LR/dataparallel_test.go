@@ -0,0 +1,82 @@
+package LR
+
+import (
+	"allreduce"
+	"config"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+)
+
+func denseItems(n int) []IndexTrainItem {
+	r := rand.New(rand.NewSource(1))
+	items := make([]IndexTrainItem, n)
+	for i := range items {
+		label := r.Intn(3)
+		fs := make([]float64, 784)
+		for j := range fs {
+			if j%3 == label && r.Float64() < 0.5 {
+				fs[j] = r.Float64()
+			}
+		}
+		items[i] = IndexTrainItem{Label: label, Features: fs}
+	}
+	return items
+}
+
+func TestFitDataParallelMatchesSingleProcess(t *testing.T) {
+	conf := config.TrainConf{OneBatch: 12, LearningRate: 0.5, Order: OrderFile}
+	items := denseItems(250)
+
+	single := &SoftMaxRegression{}
+	single.Fit(conf, items, nil, 2)
+	if single.weights[0].At(0) == 0 && single.bias[0] == 0 {
+		t.Fatal("single process training did not move the weights")
+	}
+
+	world := 4
+	listeners := make([]net.Listener, world)
+	for i := range listeners {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = listener
+	}
+	models := make([]*SoftMaxRegression, world)
+	wg := sync.WaitGroup{}
+	for i := 0; i < world; i++ {
+		wg.Add(1)
+		go func(rank int) {
+			defer wg.Done()
+			ring, err := allreduce.Connect(rank, world, listeners[rank], listeners[(rank+1)%world].Addr().String())
+			listeners[rank].Close()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer ring.Close()
+			models[rank] = &SoftMaxRegression{}
+			if err := models[rank].FitDataParallel(conf, ShardDense(items, rank, world), nil, 2, ring); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for rank, model := range models {
+		for i := range single.weights {
+			for j := 0; j < single.weights[i].Len(); j++ {
+				w := single.weights[i].At(j)
+				if math.Abs(model.weights[i].At(j)-w) > 1e-9 {
+					t.Fatalf("rank %d weight [%d][%d] %g, single process %g", rank, i, j, model.weights[i].At(j), w)
+				}
+			}
+			if math.Abs(model.bias[i]-single.bias[i]) > 1e-9 {
+				t.Fatalf("rank %d bias %d %g, single process %g", rank, i, model.bias[i], single.bias[i])
+			}
+		}
+	}
+}
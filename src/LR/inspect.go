@@ -0,0 +1,291 @@
+package LR
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"logging"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type WeightItem struct {
+	Index  int     `json:"index"`
+	Name   string  `json:"name"`
+	Field  string  `json:"field"`
+	Weight float64 `json:"weight"`
+}
+
+type FieldImportance struct {
+	Field     string  `json:"field"`
+	Count     int     `json:"count"`
+	NonZero   int     `json:"nonZero"`
+	L1        float64 `json:"l1"`
+	L2        float64 `json:"l2"`
+	MaxAbs    float64 `json:"maxAbs"`
+	Share     float64 `json:"share"`
+	TopWeight float64 `json:"topWeight"`
+}
+
+type HistogramBin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int     `json:"count"`
+}
+
+type WeightReport struct {
+	FeatureLen  int               `json:"featureLen"`
+	Bias        float64           `json:"bias"`
+	NonZero     int               `json:"nonZero"`
+	Sparsity    float64           `json:"sparsity"`
+	L1Norm      float64           `json:"l1Norm"`
+	L2Norm      float64           `json:"l2Norm"`
+	TopPositive []WeightItem      `json:"topPositive"`
+	TopNegative []WeightItem      `json:"topNegative"`
+	Histogram   []HistogramBin    `json:"histogram"`
+	Fields      []FieldImportance `json:"fields"`
+}
+
+// LoadModel reads a model written by Train.
+func LoadModel(modelPath string) (*LogisticRegression, error) {
+	data, err := ioutil.ReadFile(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	lr := &LogisticRegression{}
+	if err := json.Unmarshal(data, lr); err != nil {
+		return nil, err
+	}
+	lr.Weights.Convert(lr.Precision)
+	if lr.FeatureLen == 0 {
+		lr.FeatureLen = lr.Weights.Len()
+	}
+	return lr, nil
+}
+
+// LoadFeatureDict reads `index name` lines, separated by tab or space.
+func LoadFeatureDict(path string) (map[int]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dict := make(map[int]string)
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items := strings.Fields(line)
+		index, err := strconv.Atoi(items[0])
+		if len(items) < 2 || err != nil {
+			logging.Warn("malformed feature dict line", "path", path, "line", lineNo, "text", line)
+			continue
+		}
+		dict[index] = strings.Join(items[1:], " ")
+	}
+	return dict, scanner.Err()
+}
+
+// fieldOf returns the part of a feature name before fieldSep, e.g.
+// `city=beijing` belongs to field `city`.
+func fieldOf(name, fieldSep string) string {
+	if fieldSep == "" {
+		return name
+	}
+	if pos := strings.Index(name, fieldSep); pos > 0 {
+		return name[:pos]
+	}
+	return name
+}
+
+func (lr *LogisticRegression) Report(dict map[int]string, topK, bins int, fieldSep string) *WeightReport {
+	report := &WeightReport{FeatureLen: lr.Weights.Len(), Bias: lr.Bias}
+
+	var items []WeightItem
+	fields := make(map[string]*FieldImportance)
+	minW, maxW := math.Inf(1), math.Inf(-1)
+	for i, w := range lr.Weights.Float64s() {
+		name, ok := dict[i]
+		if !ok {
+			name = strconv.Itoa(i)
+		}
+		field := fieldOf(name, fieldSep)
+		if !ok {
+			field = "unknown"
+		}
+
+		fi, ok := fields[field]
+		if !ok {
+			fi = &FieldImportance{Field: field}
+			fields[field] = fi
+		}
+		fi.Count++
+		if w == 0 {
+			continue
+		}
+
+		abs := math.Abs(w)
+		report.NonZero++
+		report.L1Norm += abs
+		report.L2Norm += w * w
+		fi.NonZero++
+		fi.L1 += abs
+		fi.L2 += w * w
+		if abs > fi.MaxAbs {
+			fi.MaxAbs = abs
+			fi.TopWeight = w
+		}
+		minW = math.Min(minW, w)
+		maxW = math.Max(maxW, w)
+		items = append(items, WeightItem{Index: i, Name: name, Field: field, Weight: w})
+	}
+	report.L2Norm = math.Sqrt(report.L2Norm)
+	if report.FeatureLen > 0 {
+		report.Sparsity = 1 - float64(report.NonZero)/float64(report.FeatureLen)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Weight > items[j].Weight })
+	for i := 0; i < len(items) && i < topK && items[i].Weight > 0; i++ {
+		report.TopPositive = append(report.TopPositive, items[i])
+	}
+	for i := len(items) - 1; i >= 0 && len(items)-1-i < topK && items[i].Weight < 0; i-- {
+		report.TopNegative = append(report.TopNegative, items[i])
+	}
+
+	report.Histogram = histogram(items, minW, maxW, bins)
+
+	for _, fi := range fields {
+		fi.L2 = math.Sqrt(fi.L2)
+		if report.L1Norm > 0 {
+			fi.Share = fi.L1 / report.L1Norm
+		}
+		report.Fields = append(report.Fields, *fi)
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		if report.Fields[i].L1 == report.Fields[j].L1 {
+			return report.Fields[i].Field < report.Fields[j].Field
+		}
+		return report.Fields[i].L1 > report.Fields[j].L1
+	})
+	return report
+}
+
+// histogram buckets non-zero weights into equal width bins.
+func histogram(items []WeightItem, minW, maxW float64, bins int) []HistogramBin {
+	if bins <= 0 || len(items) == 0 {
+		return nil
+	}
+	width := (maxW - minW) / float64(bins)
+	result := make([]HistogramBin, bins)
+	for i := range result {
+		result[i].Low = minW + float64(i)*width
+		result[i].High = minW + float64(i+1)*width
+	}
+	result[bins-1].High = maxW
+	for _, item := range items {
+		bi := bins - 1
+		if width > 0 {
+			bi = int((item.Weight - minW) / width)
+		}
+		if bi >= bins {
+			bi = bins - 1
+		}
+		result[bi].Count++
+	}
+	return result
+}
+
+func (r *WeightReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteCSV writes every section as rows of one table:
+// section,rank,index,name,field,value
+func (r *WeightReport) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	out.Write([]string{"section", "rank", "index", "name", "field", "value"})
+	out.Write([]string{"summary", "", "", "feature_len", "", strconv.Itoa(r.FeatureLen)})
+	out.Write([]string{"summary", "", "", "non_zero", "", strconv.Itoa(r.NonZero)})
+	out.Write([]string{"summary", "", "", "sparsity", "", f(r.Sparsity)})
+	out.Write([]string{"summary", "", "", "l1_norm", "", f(r.L1Norm)})
+	out.Write([]string{"summary", "", "", "l2_norm", "", f(r.L2Norm)})
+	out.Write([]string{"summary", "", "", "bias", "", f(r.Bias)})
+	for i, item := range r.TopPositive {
+		out.Write([]string{"top_positive", strconv.Itoa(i + 1),
+			strconv.Itoa(item.Index), item.Name, item.Field, f(item.Weight)})
+	}
+	for i, item := range r.TopNegative {
+		out.Write([]string{"top_negative", strconv.Itoa(i + 1),
+			strconv.Itoa(item.Index), item.Name, item.Field, f(item.Weight)})
+	}
+	for i, bin := range r.Histogram {
+		out.Write([]string{"histogram", strconv.Itoa(i + 1), "",
+			fmt.Sprintf("[%s,%s)", f(bin.Low), f(bin.High)), "", strconv.Itoa(bin.Count)})
+	}
+	for i, fi := range r.Fields {
+		out.Write([]string{"field_l1", strconv.Itoa(i + 1), "", fi.Field, fi.Field, f(fi.L1)})
+		out.Write([]string{"field_share", strconv.Itoa(i + 1), "", fi.Field, fi.Field, f(fi.Share)})
+		out.Write([]string{"field_non_zero", strconv.Itoa(i + 1), "", fi.Field, fi.Field, strconv.Itoa(fi.NonZero)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (r *WeightReport) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "features %d, non zero %d, sparsity %.04f, bias %.06f\n",
+		r.FeatureLen, r.NonZero, r.Sparsity, r.Bias)
+	fmt.Fprintf(w, "l1 norm %.06f, l2 norm %.06f\n", r.L1Norm, r.L2Norm)
+
+	fmt.Fprintln(w, "\ntop positive weights")
+	for i, item := range r.TopPositive {
+		fmt.Fprintf(w, "%4d %8d %-40s %+.06f\n", i+1, item.Index, item.Name, item.Weight)
+	}
+	fmt.Fprintln(w, "\ntop negative weights")
+	for i, item := range r.TopNegative {
+		fmt.Fprintf(w, "%4d %8d %-40s %+.06f\n", i+1, item.Index, item.Name, item.Weight)
+	}
+
+	fmt.Fprintln(w, "\nweight histogram (non zero)")
+	maxCount := 0
+	for _, bin := range r.Histogram {
+		if bin.Count > maxCount {
+			maxCount = bin.Count
+		}
+	}
+	for _, bin := range r.Histogram {
+		bar := 0
+		if maxCount > 0 {
+			bar = bin.Count * 50 / maxCount
+		}
+		fmt.Fprintf(w, "[%+.04f, %+.04f) %8d %s\n",
+			bin.Low, bin.High, bin.Count, strings.Repeat("#", bar))
+	}
+
+	fmt.Fprintln(w, "\nfield importance")
+	fmt.Fprintf(w, "%-30s %8s %8s %12s %8s %12s\n", "field", "count", "nonzero", "l1", "share", "max")
+	for _, fi := range r.Fields {
+		_, err := fmt.Fprintf(w, "%-30s %8d %8d %12.06f %8.04f %+12.06f\n",
+			fi.Field, fi.Count, fi.NonZero, fi.L1, fi.Share, fi.TopWeight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
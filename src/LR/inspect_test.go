@@ -0,0 +1,132 @@
+package LR
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"param"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func inspectFixture(t *testing.T) (*WeightReport, func()) {
+	dir, err := ioutil.TempDir("", "inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dictPath := filepath.Join(dir, "features.txt")
+	content := "# index name\n0 city=bj\n1\tcity=sh\nage=20\n3 age=20\n\n"
+	if err := ioutil.WriteFile(dictPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dict, err := LoadFeatureDict(dictPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{0: "city=bj", 1: "city=sh", 3: "age=20"}; !reflect.DeepEqual(dict, want) {
+		t.Fatalf("dict %v, want %v", dict, want)
+	}
+
+	model := &LogisticRegression{Weights: param.FromFloat64s([]float64{0.5, -0.25, 0, 1, -2}), Bias: 0.125}
+	modelPath := filepath.Join(dir, "lr.model")
+	saved, _ := json.Marshal(model)
+	if err := ioutil.WriteFile(modelPath, saved, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadModel(modelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.FeatureLen != 5 {
+		t.Errorf("feature len %d of a model without it", loaded.FeatureLen)
+	}
+	return loaded.Report(dict, 2, 2, "="), func() { os.RemoveAll(dir) }
+}
+
+const inspectText = `features 5, non zero 4, sparsity 0.2000, bias 0.125000
+l1 norm 3.750000, l2 norm 2.304886
+
+top positive weights
+   1        3 age=20                                   +1.000000
+   2        0 city=bj                                  +0.500000
+
+top negative weights
+   1        4 4                                        -2.000000
+   2        1 city=sh                                  -0.250000
+
+weight histogram (non zero)
+[-2.0000, -0.5000)        1 ################
+[-0.5000, +1.0000)        3 ##################################################
+
+field importance
+field                             count  nonzero           l1    share          max
+unknown                               2        1     2.000000   0.5333    -2.000000
+age                                   1        1     1.000000   0.2667    +1.000000
+city                                  2        2     0.750000   0.2000    +0.500000
+`
+
+const inspectCSV = `section,rank,index,name,field,value
+summary,,,feature_len,,5
+summary,,,non_zero,,4
+summary,,,sparsity,,0.19999999999999996
+summary,,,l1_norm,,3.75
+summary,,,l2_norm,,2.3048861143232218
+summary,,,bias,,0.125
+top_positive,1,3,age=20,age,1
+top_positive,2,0,city=bj,city,0.5
+top_negative,1,4,4,unknown,-2
+top_negative,2,1,city=sh,city,-0.25
+histogram,1,,"[-2,-0.5)",,1
+histogram,2,,"[-0.5,1)",,3
+field_l1,1,,unknown,unknown,2
+field_share,1,,unknown,unknown,0.5333333333333333
+field_non_zero,1,,unknown,unknown,1
+field_l1,2,,age,age,1
+field_share,2,,age,age,0.26666666666666666
+field_non_zero,2,,age,age,1
+field_l1,3,,city,city,0.75
+field_share,3,,city,city,0.2
+field_non_zero,3,,city,city,2
+`
+
+func TestReportText(t *testing.T) {
+	report, cleanup := inspectFixture(t)
+	defer cleanup()
+	out := &bytes.Buffer{}
+	if err := report.WriteText(out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != inspectText {
+		t.Errorf("text report\n%s\nwant\n%s", out.String(), inspectText)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	report, cleanup := inspectFixture(t)
+	defer cleanup()
+	out := &bytes.Buffer{}
+	if err := report.WriteCSV(out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != inspectCSV {
+		t.Errorf("csv report\n%s\nwant\n%s", out.String(), inspectCSV)
+	}
+}
+
+func TestReportJSONRoundTrip(t *testing.T) {
+	report, cleanup := inspectFixture(t)
+	defer cleanup()
+	out := &bytes.Buffer{}
+	if err := report.WriteJSON(out); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &WeightReport{}
+	if err := json.Unmarshal(out.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, report) {
+		t.Errorf("json decodes to %+v, want %+v", decoded, report)
+	}
+}
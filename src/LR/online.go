@@ -0,0 +1,195 @@
+package LR
+
+import (
+	"bufio"
+	"config"
+	"fmt"
+	"io"
+	"logging"
+	"metrics"
+	"os"
+	"param"
+	"time"
+)
+
+const (
+	DefaultOnlineWindow    int    = 10000
+	DefaultSnapshotSeconds int    = 60
+	OnlineModelName        string = "online.model"
+	followPollInterval            = 500 * time.Millisecond
+)
+
+// Online updates a LogisticRegression one example at a time. Every example
+// is scored before the update, so the rolling window is a progressive
+// validation of the live model. A calibrator fitted offline is dropped, it
+// no longer matches once the weights move.
+type Online struct {
+	lr           *LogisticRegression
+	learningRate float64
+	window       *metrics.Window
+	seen         int
+	snapshotPath string
+	interval     time.Duration
+	// throughput is measured between reports
+	reportedAt   time.Time
+	reportedSeen int
+}
+
+func NewOnline(lr *LogisticRegression, conf config.TrainConf) *Online {
+	if lr.Weights.IsNil() {
+		lr.FeatureLen = conf.FeatureLen
+		lr.Precision = conf.Precision
+		lr.Weights = param.NewVector(lr.FeatureLen, lr.Precision)
+	}
+	if lr.Calibrator != nil {
+		logging.Warn("online updates drop the calibrator", "method", lr.Calibrator.Method)
+		lr.Calibrator = nil
+	}
+	windowSize := conf.OnlineWindow
+	if windowSize <= 0 {
+		windowSize = DefaultOnlineWindow
+	}
+	seconds := conf.SnapshotSeconds
+	if seconds <= 0 {
+		seconds = DefaultSnapshotSeconds
+	}
+	return &Online{
+		lr:           lr,
+		learningRate: conf.LearningRate,
+		window:       metrics.NewWindow(windowSize),
+		snapshotPath: fmt.Sprintf("%s/%s", conf.ModelPath, OnlineModelName),
+		interval:     time.Duration(seconds) * time.Second,
+		reportedAt:   time.Now(),
+	}
+}
+
+// grow makes room for feature indexes that were not seen at start.
+func (o *Online) grow(index int) {
+	if index < o.lr.Weights.Len() {
+		return
+	}
+	size := 2 * o.lr.Weights.Len()
+	if size <= index {
+		size = index + 1
+	}
+	o.lr.Weights.Grow(size)
+	o.lr.FeatureLen = size
+}
+
+func (o *Online) Learn(item *SparseTrainItem) float64 {
+	for k := range item.Features {
+		o.grow(k)
+	}
+	p := o.lr.RawScore(item)
+	o.window.Add(p, item.Label)
+	o.seen++
+
+	residual := float64(item.Label) - p
+	o.lr.Bias += o.learningRate * residual
+	for k, score := range item.Features {
+		o.lr.Weights.Add(k, o.learningRate*residual*score)
+	}
+	return p
+}
+
+func (o *Online) Snapshot() error {
+	return o.lr.Save(o.snapshotPath)
+}
+
+// report logs the window, its logloss is the progressive train loss.
+func (o *Online) report() {
+	result := o.window.Result()
+	now := time.Now()
+	seconds := now.Sub(o.reportedAt).Seconds()
+	logging.With(result.Fields()...).Info("online", "seen", o.seen, "window", o.window.Len())
+	o.lr.Recorder.Record(metrics.Record{Step: o.seen, TrainLoss: result[metrics.LogLoss],
+		LearningRate: o.learningRate, Throughput: float64(o.seen-o.reportedSeen) / seconds,
+		Seconds: seconds, Validation: result})
+	o.reportedAt, o.reportedSeen = now, o.seen
+}
+
+// Run learns from every line of input until it ends or stop fires, the model
+// is snapshotted every interval and once more at the end.
+func (o *Online) Run(input io.Reader, stop <-chan os.Signal) error {
+	lines := make(chan string, 1024)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		readErr <- scanner.Err()
+		close(lines)
+	}()
+
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				o.report()
+				if err := o.Snapshot(); err != nil {
+					return err
+				}
+				logging.Info("input done, model saved", "path", o.snapshotPath)
+				return <-readErr
+			}
+			if item, ok := parseSparseLine(line); ok {
+				o.Learn(&item)
+			}
+		case <-ticker.C:
+			o.report()
+			if err := o.Snapshot(); err != nil {
+				logging.Error("snapshot", "path", o.snapshotPath, "err", err)
+			} else {
+				logging.Info("snapshot saved", "path", o.snapshotPath)
+			}
+		case <-stop:
+			o.report()
+			if err := o.Snapshot(); err != nil {
+				return err
+			}
+			logging.Info("stopped, model saved", "path", o.snapshotPath)
+			return nil
+		}
+	}
+}
+
+// followReader reads a file like `tail -f`, it waits for appended data
+// instead of returning io.EOF and reopens the file when it is truncated.
+type followReader struct {
+	path   string
+	file   *os.File
+	offset int64
+}
+
+func Follow(path string) (io.Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &followReader{path: path, file: file}, nil
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		time.Sleep(followPollInterval)
+		if info, err := os.Stat(f.path); err == nil && info.Size() < f.offset {
+			logging.Warn("file truncated, reopen", "path", f.path)
+			if file, err := os.Open(f.path); err == nil {
+				f.file.Close()
+				f.file = file
+				f.offset = 0
+			}
+		}
+	}
+}
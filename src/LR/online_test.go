@@ -0,0 +1,139 @@
+package LR
+
+import (
+	"bufio"
+	"config"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sparseLine(item SparseTrainItem) string {
+	parts := []string{fmt.Sprint(item.Label)}
+	for k, v := range item.Features {
+		parts = append(parts, fmt.Sprintf("%d:%g", k, v))
+	}
+	return strings.Join(parts, Sep)
+}
+
+func appendLines(t *testing.T, path string, items []SparseTrainItem) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, item := range items {
+		fmt.Fprintln(file, sparseLine(item))
+	}
+}
+
+func TestFollowReadsAppendedLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "input.log")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input, err := Follow(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("no line within 5s")
+		}
+		return ""
+	}
+	if line := next(); line != "first" {
+		t.Fatalf("read %q", line)
+	}
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	fmt.Fprintln(file, "second")
+	file.Close()
+	if line := next(); line != "second" {
+		t.Fatalf("read %q after append", line)
+	}
+	// truncated and rewritten shorter, the reader starts over
+	if err := ioutil.WriteFile(path, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if line := next(); line != "x" {
+		t.Fatalf("read %q after truncation", line)
+	}
+}
+
+func TestOnlineFollowWindowAndSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "online")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "input.log")
+	items := sparseItems(120, 30, 4)
+	appendLines(t, path, items[:80])
+
+	conf := config.TrainConf{FeatureLen: 10, LearningRate: 0.05, OnlineWindow: 50, ModelPath: dir}
+	input, err := Follow(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewOnline(&LogisticRegression{}, conf)
+	stop := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- o.Run(input, stop) }()
+	time.Sleep(200 * time.Millisecond)
+	appendLines(t, path, items[80:])
+	// the follow reader polls every 500ms
+	time.Sleep(4 * followPollInterval)
+	stop <- os.Interrupt
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the same items learnt in file order give the same progressive window
+	replay := NewOnline(&LogisticRegression{}, conf)
+	for i := range items {
+		replay.Learn(&items[i])
+	}
+	if o.seen != len(items) || o.window.Len() != 50 {
+		t.Fatalf("seen %d, window %d", o.seen, o.window.Len())
+	}
+	if got, want := o.window.Result().String(), replay.window.Result().String(); got != want {
+		t.Errorf("window %s, replay %s", got, want)
+	}
+
+	loaded, err := LoadModel(filepath.Join(dir, OnlineModelName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Weights.Len() != o.lr.Weights.Len() || math.Abs(loaded.Bias-o.lr.Bias) > 1e-12 {
+		t.Fatalf("snapshot has %d weights bias %v, model %d %v",
+			loaded.Weights.Len(), loaded.Bias, o.lr.Weights.Len(), o.lr.Bias)
+	}
+	for i := range items {
+		if got, want := loaded.Score(&items[i]), o.lr.Score(&items[i]); math.Abs(got-want) > 1e-12 {
+			t.Fatalf("snapshot scores %v, model %v", got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, OnlineModelName+".tmp")); !os.IsNotExist(err) {
+		t.Error("snapshot left its temp file")
+	}
+}
@@ -0,0 +1,94 @@
+package LR
+
+import (
+	"config"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"param"
+	"testing"
+)
+
+func sparseItems(n, featureLen int, seed int64) []SparseTrainItem {
+	r := rand.New(rand.NewSource(seed))
+	items := make([]SparseTrainItem, n)
+	for i := range items {
+		label := r.Intn(2)
+		fs := make(map[int]float64)
+		for len(fs) < 10 {
+			k := r.Intn(featureLen)
+			// even features lean positive, odd ones negative
+			if (k%2 == 0) == (label == 1) || r.Float64() < 0.3 {
+				fs[k] = 1
+			}
+		}
+		items[i] = SparseTrainItem{Label: label, Features: fs}
+	}
+	return items
+}
+
+func TestLRFloat32MatchesFloat64(t *testing.T) {
+	training, testing := sparseItems(2000, 200, 1), sparseItems(500, 200, 2)
+	conf := config.TrainConf{FeatureLen: 200, OneBatch: 50, LearningRate: 0.5, Order: OrderFile}
+
+	double := &LogisticRegression{}
+	double.Fit(conf, training, testing, 5)
+	conf.Precision = param.Float32
+	single := &LogisticRegression{}
+	single.Fit(conf, training, testing, 5)
+	if single.Weights.Precision() != param.Float32 {
+		t.Fatalf("weights stored as %s", single.Weights.Precision())
+	}
+
+	a, b := double.Evaluate(testing), single.Evaluate(testing)
+	if math.Abs(a["auc"]-b["auc"]) > 0.01 || math.Abs(a["accuracy"]-b["accuracy"]) > 0.01 {
+		t.Errorf("float64 %s, float32 %s", a.String(), b.String())
+	}
+	if a["accuracy"] < 0.7 {
+		t.Errorf("model did not learn: %s", a.String())
+	}
+
+	data, err := json.Marshal(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &LogisticRegression{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Weights.Convert(loaded.Precision)
+	if loaded.Weights.Precision() != param.Float32 {
+		t.Errorf("loaded weights as %s", loaded.Weights.Precision())
+	}
+	for k := 0; k < single.Weights.Len(); k++ {
+		if loaded.Weights.At(k) != single.Weights.At(k) {
+			t.Fatalf("weight %d %v changed to %v after save", k, single.Weights.At(k), loaded.Weights.At(k))
+		}
+	}
+}
+
+func TestSoftmaxFloat32MatchesFloat64(t *testing.T) {
+	items := denseItems(300)
+	conf := config.TrainConf{OneBatch: 10, LearningRate: 0.5, Order: OrderFile}
+
+	double := &SoftMaxRegression{}
+	double.Fit(conf, items[:240], nil, 3)
+	conf.Precision = param.Float32
+	single := &SoftMaxRegression{}
+	single.Fit(conf, items[:240], nil, 3)
+
+	maxDiff := 0.0
+	for i := range double.weights {
+		for j := 0; j < double.weights[i].Len(); j++ {
+			maxDiff = math.Max(maxDiff, math.Abs(double.weights[i].At(j)-single.weights[i].At(j)))
+		}
+	}
+	if maxDiff > 1e-4 {
+		t.Errorf("float32 weights differ by %g", maxDiff)
+	}
+	a, b := double.Evaluate(items[240:]), single.Evaluate(items[240:])
+	if math.Abs(a["accuracy"]-b["accuracy"]) > 0.02 {
+		t.Errorf("float64 %s, float32 %s", a.String(), b.String())
+	}
+	t.Logf("max weight difference %g, float64 %s, float32 %s", maxDiff, a.String(), b.String())
+}
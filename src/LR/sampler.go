@@ -0,0 +1,149 @@
+package LR
+
+import (
+	"config"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	OrderFile       string = "file"
+	OrderShuffle    string = "shuffle"
+	OrderWindow     string = "window"
+	OrderStratified string = "stratified"
+	OrderBootstrap  string = "bootstrap"
+
+	DefaultShuffleWindow int = 10000
+)
+
+// Sampler decides in which order training items are visited in one epoch.
+// Epoch returns indexes into the training set, bootstrap may repeat indexes.
+type Sampler struct {
+	order  string
+	window int
+	labels []int
+	r      *rand.Rand
+}
+
+// NewSampler builds a sampler for `count` training items. labels is only
+// needed by the stratified order and may be nil otherwise.
+func NewSampler(order string, window int, seed int64, labels []int, count int) *Sampler {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if window <= 0 {
+		window = DefaultShuffleWindow
+	}
+	switch order {
+	case OrderFile, OrderShuffle, OrderWindow, OrderBootstrap:
+	case OrderStratified:
+		if len(labels) != count {
+			panic("stratified order needs one label per training item")
+		}
+	default:
+		panic(fmt.Sprintf("unknown data order %q", order))
+	}
+	return &Sampler{
+		order:  order,
+		window: window,
+		labels: labels,
+		r:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func newSampler(conf config.TrainConf, defaultOrder string, labels []int) *Sampler {
+	order := conf.Order
+	if order == "" {
+		order = defaultOrder
+	}
+	return NewSampler(order, conf.ShuffleWindow, conf.Seed, labels, len(labels))
+}
+
+func (s *Sampler) Order() string {
+	return s.order
+}
+
+func (s *Sampler) Epoch(count int) []int {
+	switch s.order {
+	case OrderShuffle:
+		return s.shuffle(count)
+	case OrderWindow:
+		return s.windowShuffle(count)
+	case OrderStratified:
+		return s.stratified(count)
+	case OrderBootstrap:
+		return s.bootstrap(count)
+	}
+	return identity(count)
+}
+
+func identity(count int) []int {
+	result := make([]int, count)
+	for i := 0; i < count; i++ {
+		result[i] = i
+	}
+	return result
+}
+
+func (s *Sampler) knuthShuffle(vals []int) {
+	for len(vals) > 0 {
+		n := len(vals)
+		randIndex := s.r.Intn(n)
+		vals[n-1], vals[randIndex] = vals[randIndex], vals[n-1]
+		vals = vals[:n-1]
+	}
+}
+
+func (s *Sampler) shuffle(count int) []int {
+	result := identity(count)
+	s.knuthShuffle(result)
+	return result
+}
+
+// windowShuffle only shuffles inside consecutive windows, which is what a
+// streaming reader with a fixed size buffer can afford.
+func (s *Sampler) windowShuffle(count int) []int {
+	result := identity(count)
+	for start := 0; start < count; start += s.window {
+		end := start + s.window
+		if end > count {
+			end = count
+		}
+		s.knuthShuffle(result[start:end])
+	}
+	return result
+}
+
+// stratified spreads every label evenly over the epoch, so any contiguous
+// mini-batch keeps roughly the global label proportion.
+func (s *Sampler) stratified(count int) []int {
+	byLabel := make(map[int][]int)
+	for i := 0; i < count; i++ {
+		byLabel[s.labels[i]] = append(byLabel[s.labels[i]], i)
+	}
+
+	keys := make([]float64, count)
+	for _, indexes := range byLabel {
+		s.knuthShuffle(indexes)
+		n := float64(len(indexes))
+		for rank, index := range indexes {
+			keys[index] = (float64(rank) + s.r.Float64()) / n
+		}
+	}
+
+	result := identity(count)
+	sort.SliceStable(result, func(i, j int) bool {
+		return keys[result[i]] < keys[result[j]]
+	})
+	return result
+}
+
+func (s *Sampler) bootstrap(count int) []int {
+	result := make([]int, count)
+	for i := 0; i < count; i++ {
+		result[i] = s.r.Intn(count)
+	}
+	return result
+}
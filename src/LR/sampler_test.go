@@ -0,0 +1,106 @@
+package LR
+
+import (
+	"sort"
+	"testing"
+)
+
+func isPermutation(order []int, count int) bool {
+	sorted := append([]int(nil), order...)
+	sort.Ints(sorted)
+	for i, index := range sorted {
+		if index != i {
+			return false
+		}
+	}
+	return len(sorted) == count
+}
+
+func TestShuffleIsPermutation(t *testing.T) {
+	s := NewSampler(OrderShuffle, 0, 7, nil, 500)
+	first := s.Epoch(500)
+	if !isPermutation(first, 500) {
+		t.Fatal("shuffle is not a permutation")
+	}
+	if second := s.Epoch(500); !isPermutation(second, 500) {
+		t.Fatal("second shuffle is not a permutation")
+	} else if equal(first, second) {
+		t.Error("two epochs in the same order")
+	}
+	if again := NewSampler(OrderShuffle, 0, 7, nil, 500).Epoch(500); !equal(first, again) {
+		t.Error("same seed, different order")
+	}
+}
+
+func TestWindowStaysInWindow(t *testing.T) {
+	const count, window = 1050, 100
+	order := NewSampler(OrderWindow, window, 3, nil, count).Epoch(count)
+	if !isPermutation(order, count) {
+		t.Fatal("window order is not a permutation")
+	}
+	for position, index := range order {
+		if position/window != index/window {
+			t.Fatalf("item %d moved to position %d", index, position)
+		}
+	}
+}
+
+func TestStratifiedKeepsBatchProportions(t *testing.T) {
+	const count, batch = 1000, 100
+	labels := make([]int, count)
+	for i := range labels {
+		// 30% positives, all at the end of the file
+		if i >= 700 {
+			labels[i] = 1
+		}
+	}
+	order := NewSampler(OrderStratified, 0, 5, labels, count).Epoch(count)
+	if !isPermutation(order, count) {
+		t.Fatal("stratified order is not a permutation")
+	}
+	for start := 0; start < count; start += batch {
+		positives := 0
+		for _, index := range order[start : start+batch] {
+			positives += labels[index]
+		}
+		if positives < 28 || positives > 32 {
+			t.Errorf("batch at %d has %d positives of %d", start, positives, batch)
+		}
+	}
+}
+
+func TestBootstrapLength(t *testing.T) {
+	order := NewSampler(OrderBootstrap, 0, 9, nil, 300).Epoch(300)
+	if len(order) != 300 {
+		t.Fatalf("bootstrap of %d items", len(order))
+	}
+	seen := make(map[int]bool)
+	for _, index := range order {
+		if index < 0 || index >= 300 {
+			t.Fatalf("index %d out of range", index)
+		}
+		seen[index] = true
+	}
+	// about 1 - 1/e of the items are drawn
+	if len(seen) == 300 || len(seen) < 150 {
+		t.Errorf("%d distinct items drawn", len(seen))
+	}
+}
+
+func TestFileOrder(t *testing.T) {
+	if order := NewSampler(OrderFile, 0, 1, nil, 5).Epoch(5); !equal(order, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("file order %v", order)
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package LR
+
+import (
+	"bufio"
+	"config"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatDense  string = "dense"
+	FormatLibsvm string = "libsvm"
+
+	// text rows with many features easily pass bufio's 64k default
+	maxLineSize int = 16 * 1024 * 1024
+)
+
+// LoadSoftmaxData reads path in the format of conf, dense csv by default.
+// Labels must be in [0, LabelCount) when conf sets LabelCount.
+func LoadSoftmaxData(conf config.TrainConf, path string) (items []IndexTrainItem, err error) {
+	if conf.Format == FormatLibsvm {
+		items, err = LoadLibsvmData(path)
+	} else {
+		items, err = LoadDenseData(path, conf.FeatureLen)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if label := items[i].Label; label < 0 || conf.LabelCount > 0 && label >= conf.LabelCount {
+			return nil, fmt.Errorf("%s: item %d label %d out of range %d", path, i, label, conf.LabelCount)
+		}
+	}
+	return items, nil
+}
+
+// LoadLibsvmData reads multi-class `label index:value index:value ...` lines
+// into sparse items with ascending indexes. Values are not scaled, labels
+// count from 0, a negative label such as binary -1 is an error.
+func LoadLibsvmData(path string) (result []IndexTrainItem, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		items := strings.Fields(line)
+		if len(items) == 0 {
+			continue
+		}
+		label, err := strconv.Atoi(items[0])
+		if err != nil {
+			continue
+		}
+		if label < 0 {
+			return nil, fmt.Errorf("%s:%d: negative label %d", path, lineNo, label)
+		}
+		item := IndexTrainItem{
+			Label:    label,
+			Index:    make([]int, 0, len(items)-1),
+			Features: make([]float64, 0, len(items)-1),
+		}
+		sorted := true
+		for _, pair := range items[1:] {
+			pos := strings.IndexByte(pair, ':')
+			if pos < 0 {
+				continue
+			}
+			index, err := strconv.Atoi(pair[:pos])
+			if err != nil || index < 0 {
+				continue
+			}
+			value, err := strconv.ParseFloat(pair[pos+1:], 64)
+			if err != nil || value == 0 {
+				continue
+			}
+			if n := len(item.Index); n > 0 && item.Index[n-1] >= index {
+				sorted = false
+			}
+			item.Index = append(item.Index, index)
+			item.Features = append(item.Features, value)
+		}
+		if !sorted {
+			sort.Sort(&item)
+		}
+		result = append(result, item)
+	}
+	return result, scanner.Err()
+}
+
+// sort.Interface over the sparse pairs of an item
+func (item *IndexTrainItem) Len() int           { return len(item.Index) }
+func (item *IndexTrainItem) Less(i, j int) bool { return item.Index[i] < item.Index[j] }
+func (item *IndexTrainItem) Swap(i, j int) {
+	item.Index[i], item.Index[j] = item.Index[j], item.Index[i]
+	item.Features[i], item.Features[j] = item.Features[j], item.Features[i]
+}
+
+// InferDims returns the feature length and label count covering every item.
+func InferDims(sets ...[]IndexTrainItem) (featureLen, labelCount int) {
+	for _, items := range sets {
+		for _, item := range items {
+			if item.Label+1 > labelCount {
+				labelCount = item.Label + 1
+			}
+			if item.Index == nil {
+				if len(item.Features) > featureLen {
+					featureLen = len(item.Features)
+				}
+			} else if n := len(item.Index); n > 0 && item.Index[n-1]+1 > featureLen {
+				featureLen = item.Index[n-1] + 1
+			}
+		}
+	}
+	return
+}
+
+func confOr(confValue, inferred int) int {
+	if confValue > 0 {
+		return confValue
+	}
+	return inferred
+}
+
+func (smr *SoftMaxRegression) prepareSparse(training []IndexTrainItem) {
+	smr.sparse = false
+	for i := range training {
+		if training[i].Index != nil {
+			smr.sparse = true
+			break
+		}
+	}
+	if smr.sparse {
+		smr.touchedMark = make([]bool, smr.featureLen)
+		smr.touched = smr.touched[:0]
+	}
+}
+
+// dot is the score of label before the bias, features beyond featureLen
+// are ignored.
+func (smr *SoftMaxRegression) dot(label int, item *IndexTrainItem) float64 {
+	if item.Index != nil {
+		return smr.weights[label].DotSparse(item.Index, item.Features)
+	}
+	return smr.weights[label].DotDense(item.Features)
+}
@@ -0,0 +1,178 @@
+package LR
+
+import (
+	"config"
+	"io/ioutil"
+	"math"
+	"os"
+	"param"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// BenchmarkSoftmaxEpoch runs one epoch over MNIST shaped dense items.
+func BenchmarkSoftmaxEpoch(b *testing.B) {
+	items := denseItems(1000)
+	for i := range items {
+		items[i].Label = i % 10
+	}
+	conf := config.TrainConf{OneBatch: 10, LearningRate: 0.1, Order: OrderFile, LabelCount: 10}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		smr := &SoftMaxRegression{}
+		smr.Fit(conf, items, nil, 1)
+	}
+}
+
+func TestSoftmaxSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "softmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	items := denseItems(200)
+	for _, precision := range []string{param.Float64, param.Float32} {
+		conf := config.TrainConf{OneBatch: 10, LearningRate: 0.5, Order: OrderFile, Precision: precision}
+		model := &SoftMaxRegression{}
+		model.Fit(conf, items, nil, 2)
+		path := filepath.Join(dir, precision+".model")
+		if err := model.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadSoftmax(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.weights[0].Precision() != precision {
+			t.Errorf("%s model loaded as %s", precision, loaded.weights[0].Precision())
+		}
+		for i := range items {
+			a, b := model.probability(&items[i]), loaded.probability(&items[i])
+			if !reflect.DeepEqual(a, b) {
+				t.Fatalf("%s item %d probabilities %v, loaded %v", precision, i, a, b)
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.model"),
+		[]byte(`{"Weights":[[1,2]],"Bias":[0],"FeatureLen":3,"LabelCount":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSoftmax(filepath.Join(dir, "bad.model")); err == nil {
+		t.Error("short weight row accepted")
+	}
+}
+
+func TestLoadLibsvmData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "libsvm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "train.libsvm")
+	lines := "2 5:0.5 1:1 3:0\n\nx 1:1\n0 2:1 bad 4:x\n"
+	if err := ioutil.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := LoadLibsvmData(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IndexTrainItem{
+		{Label: 2, Index: []int{1, 5}, Features: []float64{1, 0.5}},
+		{Label: 0, Index: []int{2}, Features: []float64{1}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("loaded %v, want %v", items, want)
+	}
+
+	conf := config.TrainConf{Format: FormatLibsvm, LabelCount: 3}
+	if _, err := LoadSoftmaxData(conf, path); err != nil {
+		t.Error(err)
+	}
+	conf.LabelCount = 2
+	if _, err := LoadSoftmaxData(conf, path); err == nil {
+		t.Error("label 2 accepted with 2 labels")
+	}
+
+	negative := filepath.Join(dir, "binary.libsvm")
+	if err := ioutil.WriteFile(negative, []byte("1 1:1\n-1 2:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLibsvmData(negative); err == nil {
+		t.Error("label -1 accepted")
+	}
+}
+
+func TestInferDims(t *testing.T) {
+	dense := []IndexTrainItem{{Label: 1, Features: make([]float64, 4)}}
+	sparse := []IndexTrainItem{{Label: 4, Index: []int{2, 9}, Features: []float64{1, 1}}, {Label: 0, Index: []int{}}}
+	if featureLen, labelCount := InferDims(dense); featureLen != 4 || labelCount != 2 {
+		t.Errorf("dense dims %d %d", featureLen, labelCount)
+	}
+	if featureLen, labelCount := InferDims(dense, sparse); featureLen != 10 || labelCount != 5 {
+		t.Errorf("dense and sparse dims %d %d", featureLen, labelCount)
+	}
+	if featureLen, labelCount := InferDims(); featureLen != 0 || labelCount != 0 {
+		t.Errorf("no data dims %d %d", featureLen, labelCount)
+	}
+}
+
+// toSparse keeps the non zero features of dense items below featureLen.
+func toSparse(items []IndexTrainItem, featureLen int) []IndexTrainItem {
+	result := make([]IndexTrainItem, len(items))
+	for i, item := range items {
+		result[i] = IndexTrainItem{Label: item.Label, Index: []int{}, Features: []float64{}}
+		for j, v := range item.Features {
+			if v != 0 && j < featureLen {
+				result[i].Index = append(result[i].Index, j)
+				result[i].Features = append(result[i].Features, v)
+			}
+		}
+	}
+	return result
+}
+
+func maxWeightDiff(a, b *SoftMaxRegression) float64 {
+	diff := 0.0
+	for i := range a.weights {
+		for j := 0; j < a.weights[i].Len(); j++ {
+			diff = math.Max(diff, math.Abs(a.weights[i].At(j)-b.weights[i].At(j)))
+		}
+		diff = math.Max(diff, math.Abs(a.bias[i]-b.bias[i]))
+	}
+	return diff
+}
+
+func TestSparseFitMatchesDense(t *testing.T) {
+	items := denseItems(120)
+	conf := config.TrainConf{OneBatch: 10, LearningRate: 0.5, Order: OrderFile}
+	dense := &SoftMaxRegression{}
+	dense.Fit(conf, items, nil, 2)
+	sparse := &SoftMaxRegression{}
+	sparse.Fit(conf, toSparse(items, 784), nil, 2)
+	if !sparse.sparse {
+		t.Fatal("sparse items trained as dense")
+	}
+	if diff := maxWeightDiff(dense, sparse); diff > 1e-9 {
+		t.Errorf("sparse weights differ from dense by %g", diff)
+	}
+}
+
+func TestSparseFitIgnoresFeaturesBeyondFeatureLen(t *testing.T) {
+	items := denseItems(120)
+	conf := config.TrainConf{OneBatch: 10, LearningRate: 0.5, Order: OrderFile, FeatureLen: 500, LabelCount: 3}
+	full := &SoftMaxRegression{}
+	full.Fit(conf, toSparse(items, 784), nil, 2)
+	if full.featureLen != 500 {
+		t.Fatalf("feature length %d, conf 500", full.featureLen)
+	}
+	cut := &SoftMaxRegression{}
+	cut.Fit(conf, toSparse(items, 500), nil, 2)
+	if diff := maxWeightDiff(full, cut); diff != 0 {
+		t.Errorf("features beyond 500 moved the weights by %g", diff)
+	}
+}
@@ -2,17 +2,22 @@ package LR
 
 import (
 	"bufio"
+	"calibration"
 	"config"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"logging"
 	"math"
 	"math/rand"
+	"metrics"
 	"os"
+	"param"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"vecmath"
 )
 
 const (
@@ -21,19 +26,32 @@ const (
 )
 
 type LogisticRegression struct {
-	Weights    []float64
+	Weights    param.Vector
 	Bias       float64
 	FeatureLen int
+	Calibrator *calibration.Calibrator `json:",omitempty"`
+	// float32 keeps Weights in single precision, empty is float64
+	Precision string `json:",omitempty"`
+	// Recorder gets the metrics of every epoch when set
+	Recorder *metrics.Recorder `json:"-"`
 }
 
 type SoftMaxRegression struct {
-	weights         [][]float64
+	// Recorder gets the metrics of every epoch when set
+	Recorder *metrics.Recorder
+
+	weights         []param.Vector
 	featureLen      int
 	labelCount      int
 	lossGradient    [][]float64
 	softmax         [][]float64
 	bias            []float64
 	softmaxGradient [][][]float64
+
+	// sparse input only updates the features seen in the batch
+	sparse      bool
+	touched     []int
+	touchedMark []bool
 }
 
 type SparseTrainItem struct {
@@ -41,9 +59,51 @@ type SparseTrainItem struct {
 	Features map[int]float64
 }
 
+// IndexTrainItem is dense when Index is nil, otherwise Features[i] is the
+// value of feature Index[i].
 type IndexTrainItem struct {
 	Label    int
 	Features []float64
+	Index    []int
+}
+
+// LoadSparseData reads `label index:value index:value ...` lines.
+func LoadSparseData(path string) (result []SparseTrainItem, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if item, ok := parseSparseLine(line); ok {
+			result = append(result, item)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func parseSparseLine(line string) (SparseTrainItem, bool) {
+	items := strings.Split(line, Sep)
+	label, err := strconv.Atoi(items[0])
+	if err != nil {
+		return SparseTrainItem{}, false
+	}
+	fs := make(map[int]float64)
+	for _, item := range items[1:] {
+		pair := strings.Split(item, ":")
+		if len(pair) != 2 {
+			logging.Warn("error format", "line", line)
+			continue
+		}
+		if index, err := strconv.Atoi(pair[0]); err == nil {
+			if score, err := strconv.ParseFloat(pair[1], 64); err == nil {
+				fs[index] = score
+			}
+		}
+	}
+	return SparseTrainItem{Label: label, Features: fs}, true
 }
 
 func KnuthShuffle(vals []int) {
@@ -61,8 +121,10 @@ func (lr *LogisticRegression) sigmoid(z float64) float64 {
 }
 
 func (lr *LogisticRegression) init() {
-	lr.FeatureLen = config.GetLRConf().FeatureLen
-	lr.Weights = make([]float64, lr.FeatureLen)
+	conf := config.GetLRConf()
+	lr.FeatureLen = conf.FeatureLen
+	lr.Precision = conf.Precision
+	lr.Weights = param.NewVector(lr.FeatureLen, lr.Precision)
 }
 
 func (lr *LogisticRegression) TestLoad(modelPath string) (err error) {
@@ -73,25 +135,36 @@ func (lr *LogisticRegression) initLRModel(modelPath string) (err error) {
 	if f, err := os.Open(modelPath); err == nil {
 		if data, err := ioutil.ReadAll(f); err == nil {
 			if err = json.Unmarshal(data, lr); err == nil {
-				fmt.Println("load model from break point ", modelPath)
+				lr.Weights.Convert(lr.Precision)
+				logging.Info("load model from break point", "path", modelPath)
 			} else {
-				fmt.Println("broken file ", err.Error())
+				logging.Warn("broken break point", "path", modelPath, "err", err)
 			}
 		} else {
-			fmt.Println("invalid break point ", err.Error())
+			logging.Warn("invalid break point", "path", modelPath, "err", err)
 		}
 	} else {
-		fmt.Println("invalid break point ", err.Error())
+		logging.Warn("invalid break point", "path", modelPath, "err", err)
 	}
 	return
 }
 
-func (lr *LogisticRegression) Predict(item *SparseTrainItem, posScore float64) bool {
+// RawScore is the model output before calibration.
+func (lr *LogisticRegression) RawScore(item *SparseTrainItem) float64 {
 	sum := 0.0
 	for k, score := range item.Features {
-		sum += lr.Weights[k] * score
+		sum += lr.Weights.At(k) * score
 	}
-	p := lr.sigmoid(sum + lr.Bias)
+	return lr.sigmoid(sum + lr.Bias)
+}
+
+// Score is the click probability, calibrated when the model carries a calibrator.
+func (lr *LogisticRegression) Score(item *SparseTrainItem) float64 {
+	return lr.Calibrator.Calibrate(lr.RawScore(item))
+}
+
+func (lr *LogisticRegression) Predict(item *SparseTrainItem, posScore float64) bool {
+	p := lr.Score(item)
 	y := item.Label
 	if y == 1 && p >= posScore {
 		return true
@@ -108,81 +181,79 @@ func (lr *LogisticRegression) TrainMultiWorks(iter int, workerNum int) {
 }
 
 func (lr *LogisticRegression) Train(iter int) {
-	if config.GetLRConf().BPointPath == "" {
+	conf := config.GetLRConf()
+	if conf.BPointPath == "" {
 		lr.init()
 	} else {
-		if lr.initLRModel(config.GetLRConf().BPointPath) != nil {
+		if lr.initLRModel(conf.BPointPath) != nil {
 			lr.init()
 		}
 	}
 
-	learningRate := config.GetLRConf().LearningRate
-	var training []SparseTrainItem
-	trainPath := config.GetLRConf().TrainPath
-	var testing []SparseTrainItem
-	testPath := config.GetLRConf().TestPath
-	if file, err := os.Open(trainPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			items := strings.Split(line, Sep)
-			if label, err := strconv.Atoi(items[0]); err == nil {
-				fs := make(map[int]float64)
-				for _, item := range items[1:] {
-					pair := strings.Split(item, ":")
-					if len(pair) != 2 {
-						fmt.Println("error format ", line)
-						continue
-					}
-					if index, err := strconv.Atoi(pair[0]); err == nil {
-						if score, err := strconv.ParseFloat(
-							pair[1], 64); err == nil {
-							fs[index] = score
-						}
-					}
-
-				}
-				training = append(training, SparseTrainItem{Label: label, Features: fs})
-			}
-		}
-	} else {
+	training, err := LoadSparseData(conf.TrainPath)
+	if err != nil {
 		panic(err.Error())
 	}
-	if file, err := os.Open(testPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			items := strings.Split(line, Sep)
-			if label, err := strconv.Atoi(items[0]); err == nil {
-				fs := make(map[int]float64)
-				for _, item := range items[1:] {
-					pair := strings.Split(item, ":")
-					if len(pair) != 2 {
-						fmt.Println("error format ", line)
-						continue
-					}
-					if index, err := strconv.Atoi(pair[0]); err == nil {
-						if score, err := strconv.ParseFloat(pair[1], 64); err == nil {
-							fs[index] = score
-						}
-					}
-				}
-				testing = append(testing, SparseTrainItem{Label: label, Features: fs})
+	testing, err := LoadSparseData(conf.TestPath)
+	if err != nil {
+		logging.Warn("load test data", "path", conf.TestPath, "err", err)
+	}
+
+	lr.Recorder = NewRecorder("lr", conf)
+	defer lr.Recorder.Close()
+	lr.Fit(conf, training, testing, iter)
+
+	if conf.Calibration != "" && conf.CalibrationPath != "" {
+		if heldOut, err := LoadSparseData(conf.CalibrationPath); err == nil {
+			if before, after, err := lr.Calibrate(conf.Calibration, heldOut, 10); err == nil {
+				logging.With(before.Fields()...).Info("before calibration", "method", conf.Calibration)
+				logging.With(after.Fields()...).Info("after calibration", "method", conf.Calibration)
+			} else {
+				logging.Error("calibration", "err", err)
 			}
+		} else {
+			logging.Error("load calibration data", "path", conf.CalibrationPath, "err", err)
 		}
+	}
+
+	path := fmt.Sprintf("%s/%d.model", conf.ModelPath, time.Now().Unix())
+	if err := lr.Save(path); err != nil {
+		logging.Error("save model", "path", path, "err", err)
 	} else {
-		fmt.Println(err.Error())
+		logging.Info("model saved", "path", path)
 	}
+}
 
-	trainCount := len(training)
-	batchCount := config.GetLRConf().OneBatch
-	residual := make([]float64, batchCount)
+// Fit runs mini-batch SGD over training, weights are created when the model
+// has none yet. Accuracy on testing is printed after every iteration.
+func (lr *LogisticRegression) Fit(conf config.TrainConf, training, testing []SparseTrainItem, iter int) {
+	if lr.Weights.IsNil() {
+		lr.FeatureLen = conf.FeatureLen
+		lr.Precision = conf.Precision
+		lr.Weights = param.NewVector(lr.FeatureLen, lr.Precision)
+	}
+
+	trainLabels := make([]int, len(training))
+	for i, item := range training {
+		trainLabels[i] = item.Label
+	}
+	sampler := newSampler(conf, OrderFile, trainLabels)
+
+	learningRate := conf.LearningRate
+	batchCount := conf.OneBatch
 	wg := sync.WaitGroup{}
 	workNum := 8
+	step := 0
+	losses := make([]float64, workNum)
 	for it := 0; it < iter; it++ {
 		iterStart := time.Now()
+		trainLoss, trained := 0.0, 0
+		order := sampler.Epoch(len(training))
+		epoch := make([]SparseTrainItem, len(order))
+		for i, index := range order {
+			epoch[i] = training[index]
+		}
+		trainCount := len(epoch)
 		endIndex := trainCount / batchCount
 		endFlag := false
 		for batchIndex := 0; batchIndex < endIndex; batchIndex += workNum {
@@ -194,71 +265,104 @@ func (lr *LogisticRegression) Train(iter int) {
 					endFlag = true
 				}
 				wg.Add(1)
-				go func(wgg *sync.WaitGroup, start, end int) {
+				step++
+				trained += end - start
+				go func(wgg *sync.WaitGroup, worker, start, end int) {
 					defer wgg.Done()
 					updateIndex := make(map[int]int)
+					residual := make([]float64, end-start)
 					db := 0.0
-					for bi, item := range training[start:end] {
+					for bi, item := range epoch[start:end] {
 						tmp := 0.0
 						for k, score := range item.Features {
-							tmp += score * lr.Weights[k]
+							tmp += score * lr.Weights.At(k)
 							updateIndex[k] = 1
 						}
-						residual[bi] = float64(item.Label) - lr.sigmoid(tmp+lr.Bias)
+						p := lr.sigmoid(tmp + lr.Bias)
+						residual[bi] = float64(item.Label) - p
 						db += residual[bi]
+						if item.Label == 1 {
+							losses[worker] += metrics.NegLog(p)
+						} else {
+							losses[worker] += metrics.NegLog(1 - p)
+						}
 					}
 					lr.Bias += learningRate * (db / float64(end-start))
 
 					for fi := range updateIndex {
 						dwf := 0.0
-						for bi, item := range training[start:end] {
+						for bi, item := range epoch[start:end] {
 							if score, ok := item.Features[fi]; ok {
 								dwf += residual[bi] * score
 							}
 						}
-						lr.Weights[fi] += learningRate * dwf / float64(end-start)
+						lr.Weights.Add(fi, learningRate*dwf/float64(end-start))
 					}
-				}(&wg, start, end)
+				}(&wg, i, start, end)
 				if endFlag {
 					break
 				}
 			}
 			wg.Wait()
+			for i := range losses {
+				trainLoss += losses[i]
+				losses[i] = 0
+			}
 			if endFlag {
 				break
 			}
 		}
 
-		correctCount := 0
-		testCount := float64(len(testing))
-		for i := 0; i < len(testing); i++ {
-			item := testing[i]
-			if lr.Predict(&item, 0.5) {
-				correctCount += 1
-			}
+		result := lr.Evaluate(testing)
+		cost := time.Now().Sub(iterStart)
+		if trained > 0 {
+			trainLoss /= float64(trained)
 		}
-		fmt.Printf(
-			"iter %d, test ac %.06f  time cost %+v\n",
-			it, float64(correctCount)/testCount, time.Now().Sub(iterStart).String())
-	}
-
-	if data, err := json.Marshal(lr); err == nil {
-		path := fmt.Sprintf("%s/%d.model",
-			config.GetLRConf().ModelPath, time.Now().Unix())
-		if file, err := os.Create(path); err == nil {
-			defer file.Close()
-			if _, err := file.Write(data); err != nil {
-				fmt.Println(err.Error())
-			} else {
-				fmt.Println("model saved to ", path)
-			}
-		} else {
-			fmt.Println(err.Error())
-		}
-	} else {
-		fmt.Println(err.Error())
+		logging.With(result.Fields()...).Info("iter done", "iter", it, "train_loss", trainLoss, "cost", cost)
+		lr.Recorder.Record(metrics.Record{Epoch: it, Step: step, TrainLoss: trainLoss, LearningRate: learningRate,
+			Throughput: float64(trained) / cost.Seconds(), Seconds: cost.Seconds(), Validation: result})
+	}
+}
+
+// NewRecorder records to conf.History, or to <modelPath>/<model>_history.csv
+// when it is empty, and serves conf.MetricsAddr. Runs append to the file.
+// Failures are logged, a nil Recorder records nothing.
+func NewRecorder(model string, conf config.TrainConf) *metrics.Recorder {
+	path := conf.History
+	if path == "" && conf.ModelPath != "" {
+		path = fmt.Sprintf("%s/%s_history.csv", conf.ModelPath, model)
+	}
+	recorder, err := metrics.NewRecorder(model, path, conf.MetricsAddr)
+	if err != nil {
+		logging.Error("metrics recorder", "model", model, "err", err)
+		return nil
 	}
+	return recorder
+}
 
+// Evaluate reports accuracy, auc and logloss of the calibrated scores,
+// items are scored on every cpu.
+func (lr *LogisticRegression) Evaluate(items []SparseTrainItem) metrics.Result {
+	return metrics.Parallel(len(items), 0, metrics.NewBinaryAccumulator,
+		func(acc metrics.Accumulator, start, end int) {
+			binary := acc.(*metrics.BinaryAccumulator)
+			for i := start; i < end; i++ {
+				binary.Add(lr.Score(&items[i]), items[i].Label)
+			}
+		})
+}
+
+func (lr *LogisticRegression) Save(path string) error {
+	data, err := json.Marshal(lr)
+	if err != nil {
+		return err
+	}
+	// write aside and rename, readers never see a half written model
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
 }
 
 func (lr *LogisticRegression) TestCases() {
@@ -274,7 +378,7 @@ func (lr *LogisticRegression) TestCases() {
 				for _, item := range items[1:] {
 					pair := strings.Split(item, ":")
 					if len(pair) != 2 {
-						fmt.Println("error format ", line)
+						logging.Warn("error format", "line", line)
 						continue
 					}
 					if score, err := strconv.ParseFloat(pair[1], 64); err == nil {
@@ -289,16 +393,15 @@ func (lr *LogisticRegression) TestCases() {
 	}
 }
 
-func (smr *SoftMaxRegression) init() {
-	smr.featureLen = 784
-	smr.labelCount = 10
-	oneBatch := config.GetSoftmaxConf().OneBatch
+func (smr *SoftMaxRegression) init(conf config.TrainConf, featureLen, labelCount int) {
+	smr.featureLen = featureLen
+	smr.labelCount = labelCount
+	oneBatch := conf.OneBatch
 
 	smr.softmax = make([][]float64, oneBatch)
 	for i := 0; i < oneBatch; i++ {
 		smr.softmax[i] = make([]float64, smr.labelCount)
 	}
-	smr.bias = make([]float64, smr.labelCount)
 	smr.lossGradient = make([][]float64, oneBatch)
 	for i := 0; i < oneBatch; i++ {
 		smr.lossGradient[i] = make([]float64, smr.labelCount)
@@ -311,23 +414,23 @@ func (smr *SoftMaxRegression) init() {
 		}
 	}
 
-	smr.weights = make([][]float64, smr.labelCount)
+	// a model fitted before with the same dims keeps training
+	if smr.labelCount > 0 && len(smr.weights) == smr.labelCount && len(smr.bias) == smr.labelCount &&
+		smr.weights[0].Len() == smr.featureLen {
+		return
+	}
+	smr.bias = make([]float64, smr.labelCount)
+	smr.weights = make([]param.Vector, smr.labelCount)
 	for i := 0; i < smr.labelCount; i++ {
-		smr.weights[i] = make([]float64, smr.featureLen)
+		smr.weights[i] = param.NewVector(smr.featureLen, conf.Precision)
 	}
 }
 
-func (smr *SoftMaxRegression) forward(batchIndex int, x []float64) {
+func (smr *SoftMaxRegression) forward(batchIndex int, item *IndexTrainItem) {
 
 	max := -1000000000.0
 	for i := 0; i < smr.labelCount; i++ {
-		// TODO 检查weights 和x 维度是否一致
-		weights := smr.weights[i]
-		tmp := 0.0
-		for j := 0; j < len(weights); j++ {
-			tmp += weights[j] * x[j]
-		}
-		smr.softmax[batchIndex][i] = tmp + smr.bias[i]
+		smr.softmax[batchIndex][i] = smr.dot(i, item) + smr.bias[i]
 		if smr.softmax[batchIndex][i] > max {
 			max = smr.softmax[batchIndex][i]
 		}
@@ -337,9 +440,7 @@ func (smr *SoftMaxRegression) forward(batchIndex int, x []float64) {
 		smr.softmax[batchIndex][i] = math.Exp(smr.softmax[batchIndex][i] - max)
 		sum += smr.softmax[batchIndex][i]
 	}
-	for i := 0; i < smr.labelCount; i++ {
-		smr.softmax[batchIndex][i] = smr.softmax[batchIndex][i] / sum
-	}
+	vecmath.Scale(1/sum, smr.softmax[batchIndex])
 
 	for i := 0; i < smr.labelCount; i++ {
 		for j := 0; j < smr.labelCount; j++ {
@@ -356,14 +457,9 @@ func (smr *SoftMaxRegression) predict(item *IndexTrainItem) bool {
 
 	max := -10000000000.0
 	predictLabel := 0
-	weightCount := len(smr.weights[0])
 	softmax := make([]float64, smr.labelCount)
 	for i := 0; i < smr.labelCount; i++ {
-		tmp := 0.0
-		for j := 0; j < weightCount; j++ {
-			tmp += smr.weights[i][j] * item.Features[j]
-		}
-		softmax[i] = tmp + smr.bias[i]
+		softmax[i] = smr.dot(i, item) + smr.bias[i]
 		if softmax[i] > max {
 			max = softmax[i]
 			predictLabel = i
@@ -373,102 +469,196 @@ func (smr *SoftMaxRegression) predict(item *IndexTrainItem) bool {
 }
 
 func (lr *LogisticRegression) RandomWriteTest() {
-	length := len(lr.Weights)
+	length := lr.Weights.Len()
 
 	for i := 0; i < length; i++ {
 		index := rand.Float64() * float64(length)
-		lr.Weights[int(index)] = rand.Float64()
+		lr.Weights.Set(int(index), rand.Float64())
 	}
 }
 
-func (smr *SoftMaxRegression) Train(iter int) {
+// newGradient returns a flat buffer, weights of label i start at
+// i * featureLen and the biases follow all weights.
+func (smr *SoftMaxRegression) newGradient() []float64 {
+	return make([]float64, smr.labelCount*(smr.featureLen+1))
+}
 
-	smr.init()
+// gradient sums the loss gradient of batch into grad, batch must not be
+// larger than OneBatch. For sparse items only the features in the previous
+// and current batch are reset, smr.touched lists the current ones. Like dot
+// it ignores features beyond featureLen.
+func (smr *SoftMaxRegression) gradient(batch []IndexTrainItem, grad []float64) {
+	biasOffset := smr.labelCount * smr.featureLen
+	if smr.sparse {
+		for _, j := range smr.touched {
+			for i := 0; i < smr.labelCount; i++ {
+				grad[i*smr.featureLen+j] = 0
+			}
+			smr.touchedMark[j] = false
+		}
+		for i := biasOffset; i < len(grad); i++ {
+			grad[i] = 0
+		}
+		smr.touched = smr.touched[:0]
+	} else {
+		for i := range grad {
+			grad[i] = 0
+		}
+	}
 
-	trainCount := 0
-	var training []IndexTrainItem
-	var testing []IndexTrainItem
-	learningRate := config.GetSoftmaxConf().LearningRate
-	trainPath := config.GetSoftmaxConf().TrainPath
-	testPath := config.GetSoftmaxConf().TestPath
-	if file, err := os.Open(trainPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			items := strings.Split(line, ",")
-			if label, err := strconv.Atoi(items[0]); err == nil {
-				trainCount += 1
-				fs := make([]float64, smr.featureLen)
-				for i, item := range items[1:] {
-					if pixel, err := strconv.ParseFloat(item, 64); err == nil {
-						fs[i] = pixel / 255
-					}
+	for bi := range batch {
+		smr.forward(bi, &batch[bi])
+		if smr.sparse {
+			for _, j := range batch[bi].Index {
+				if j >= smr.featureLen {
+					continue
+				}
+				if !smr.touchedMark[j] {
+					smr.touchedMark[j] = true
+					smr.touched = append(smr.touched, j)
 				}
-				training = append(training, IndexTrainItem{Label: label, Features: fs})
 			}
 		}
 	}
-	if file, err := os.Open(testPath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			items := strings.Split(line, ",")
-			if label, err := strconv.Atoi(items[0]); err == nil {
-				fs := make([]float64, smr.featureLen)
-				for i, item := range items[1:] {
-					if pixel, err := strconv.ParseFloat(item, 64); err == nil {
-						fs[i] = pixel / 255
+	for i := 0; i < smr.labelCount; i++ {
+		row := grad[i*smr.featureLen : (i+1)*smr.featureLen]
+		for bi, item := range batch {
+			coef := -1.0 / smr.softmax[bi][item.Label] * smr.softmaxGradient[bi][item.Label][i]
+			if item.Index != nil {
+				for k, j := range item.Index {
+					if j >= smr.featureLen {
+						continue
 					}
+					row[j] += coef * item.Features[k]
 				}
-				testing = append(testing, IndexTrainItem{Label: label, Features: fs})
+			} else {
+				vecmath.Axpy(coef, item.Features, row)
 			}
+			grad[biasOffset+i] += coef
 		}
 	}
+}
+
+// applyGradient updates the given features, or every feature when features
+// is nil.
+func (smr *SoftMaxRegression) applyGradient(grad []float64, scale float64, features []int) {
+	biasOffset := smr.labelCount * smr.featureLen
+	for i := 0; i < smr.labelCount; i++ {
+		row := grad[i*smr.featureLen : (i+1)*smr.featureLen]
+		if features == nil && !math.IsNaN(vecmath.Sum(row)) {
+			smr.weights[i].AddScaled(-scale, row)
+		} else if features == nil {
+			// some entry is NaN, skip only those
+			for j := 0; j < smr.featureLen; j++ {
+				if dw := grad[i*smr.featureLen+j] * scale; !math.IsNaN(dw) {
+					smr.weights[i].Add(j, -dw)
+				}
+			}
+		} else {
+			for _, j := range features {
+				if dw := grad[i*smr.featureLen+j] * scale; !math.IsNaN(dw) {
+					smr.weights[i].Add(j, -dw)
+				}
+			}
+		}
+		if db := grad[biasOffset+i] * scale; !math.IsNaN(db) {
+			smr.bias[i] -= db
+		}
+	}
+}
+
+// LoadDenseData reads `label,pixel,pixel,...` lines, pixels are scaled to
+// [0, 1]. featureLen 0 takes the width of every line.
+func LoadDenseData(path string, featureLen int) (result []IndexTrainItem, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		items := strings.Split(line, ",")
+		if label, err := strconv.Atoi(items[0]); err == nil {
+			width := featureLen
+			if width == 0 {
+				width = len(items) - 1
+			}
+			fs := make([]float64, width)
+			for i, item := range items[1:] {
+				if i >= width {
+					break
+				}
+				if pixel, err := strconv.ParseFloat(item, 64); err == nil {
+					fs[i] = pixel / 255
+				}
+			}
+			result = append(result, IndexTrainItem{Label: label, Features: fs})
+		}
+	}
+	return result, scanner.Err()
+}
+
+func (smr *SoftMaxRegression) Train(iter int) {
+	conf := config.GetSoftmaxConf()
+	training, err := LoadSoftmaxData(conf, conf.TrainPath)
+	if err != nil {
+		logging.Error("load training data", "path", conf.TrainPath, "err", err)
+	}
+	testing, err := LoadSoftmaxData(conf, conf.TestPath)
+	if err != nil {
+		logging.Warn("load test data", "path", conf.TestPath, "err", err)
+	}
+	smr.Recorder = NewRecorder("softmax", conf)
+	defer smr.Recorder.Close()
+	smr.Fit(conf, training, testing, iter)
+}
+
+// Fit runs mini-batch SGD over training, a model fitted before with the same
+// dims continues from its weights.
+func (smr *SoftMaxRegression) Fit(conf config.TrainConf, training, testing []IndexTrainItem, iter int) {
+	featureLen, labelCount := InferDims(training, testing)
+	smr.init(conf, confOr(conf.FeatureLen, featureLen), confOr(conf.LabelCount, labelCount))
+	smr.prepareSparse(training)
+
+	trainCount := len(training)
+	learningRate := conf.LearningRate
 
-	randArray := make([]int, trainCount)
-	for i := 0; i < trainCount; i++ {
-		randArray[i] = i
+	trainLabels := make([]int, trainCount)
+	for i, item := range training {
+		trainLabels[i] = item.Label
 	}
-	KnuthShuffle(randArray)
+	sampler := newSampler(conf, OrderShuffle, trainLabels)
 
-	oneBatch := config.GetSoftmaxConf().OneBatch
+	oneBatch := conf.OneBatch
 	batchSize := trainCount / oneBatch
+	grad := smr.newGradient()
+	step := 0
 	for it := 0; it < iter; it++ {
+		iterStart := time.Now()
+		trainLoss, trained := 0.0, 0
+		randArray := sampler.Epoch(trainCount)
 		for batchIndex := 0; batchIndex < batchSize; batchIndex++ {
 			start := batchIndex * oneBatch
 			end := start + oneBatch
 			if end > trainCount {
 				end = trainCount
 			}
-			for batchIndex, index := range randArray[start:end] {
-				item := training[randArray[index]]
-				smr.forward(batchIndex, item.Features)
+			batch := make([]IndexTrainItem, 0, end-start)
+			for _, index := range randArray[start:end] {
+				batch = append(batch, training[index])
 			}
-			for i := 0; i < smr.labelCount; i++ {
-				for j := 0; j < smr.featureLen; j++ {
-					dw := 0.0
-					for bi, index := range randArray[start:end] {
-						item := training[randArray[index]]
-						if item.Features[j] != 0 {
-							dw += -1.0 / smr.softmax[bi][item.Label] * smr.softmaxGradient[bi][item.Label][i] * item.Features[j]
-						}
-					}
-					dw /= float64(oneBatch)
-					if !math.IsNaN(dw) {
-						smr.weights[i][j] -= learningRate * dw
-					}
-				}
-				db := 0.0
-				for bi, index := range randArray[start:end] {
-					item := training[randArray[index]]
-					db += -1.0 / smr.softmax[bi][item.Label] * smr.softmaxGradient[bi][item.Label][i]
-				}
-				db /= float64(oneBatch)
-				if !math.IsNaN(db) {
-					smr.bias[i] -= learningRate * db
-				}
+			smr.gradient(batch, grad)
+			for bi, item := range batch {
+				trainLoss += metrics.NegLog(smr.softmax[bi][item.Label])
+			}
+			trained += len(batch)
+			step++
+			if smr.sparse {
+				smr.applyGradient(grad, learningRate/float64(oneBatch), smr.touched)
+			} else {
+				smr.applyGradient(grad, learningRate/float64(oneBatch), nil)
 			}
 		}
 
@@ -480,18 +670,104 @@ func (smr *SoftMaxRegression) Train(iter int) {
 		//		correctCount += 1
 		//	}
 		//}
-		//fmt.Println("------------------- iter ", it, " ------------------ ac ", float64(correctCount)/trainCount)
-
-		correctCount := 0
-		testCount := float64(len(testing))
-		for i := 0; i < len(testing); i++ {
-			item := testing[i]
-			if smr.predict(&item) {
-				correctCount += 1
-			}
+
+		result := smr.Evaluate(testing)
+		cost := time.Now().Sub(iterStart)
+		if trained > 0 {
+			trainLoss /= float64(trained)
 		}
-		fmt.Println("------------------- iter ", it, " ------------------ ac ", float64(correctCount)/testCount)
+		logging.With(result.Fields()...).Info("iter done", "iter", it, "train_loss", trainLoss, "cost", cost)
+		smr.Recorder.Record(metrics.Record{Epoch: it, Step: step, TrainLoss: trainLoss, LearningRate: learningRate,
+			Throughput: float64(trained) / cost.Seconds(), Seconds: cost.Seconds(), Validation: result})
+	}
+}
 
-		KnuthShuffle(randArray)
+func (smr *SoftMaxRegression) probability(item *IndexTrainItem) []float64 {
+	softmax := make([]float64, smr.labelCount)
+	max := math.Inf(-1)
+	for i := 0; i < smr.labelCount; i++ {
+		softmax[i] = smr.dot(i, item) + smr.bias[i]
+		max = math.Max(max, softmax[i])
+	}
+	sum := 0.0
+	for i := range softmax {
+		softmax[i] = math.Exp(softmax[i] - max)
+		sum += softmax[i]
+	}
+	for i := range softmax {
+		softmax[i] /= sum
+	}
+	return softmax
+}
+
+// Evaluate reports accuracy and logloss over items, evaluated on every cpu.
+func (smr *SoftMaxRegression) Evaluate(items []IndexTrainItem) metrics.Result {
+	return metrics.Parallel(len(items), 0, metrics.NewMulticlassAccumulator,
+		func(acc metrics.Accumulator, start, end int) {
+			multiclass := acc.(*metrics.MulticlassAccumulator)
+			for i := start; i < end; i++ {
+				probs := smr.probability(&items[i])
+				predicted := 0
+				for li, p := range probs {
+					if p > probs[predicted] {
+						predicted = li
+					}
+				}
+				trueProb := 0.0
+				if label := items[i].Label; label < len(probs) {
+					trueProb = probs[label]
+				}
+				multiclass.Add(predicted, items[i].Label, trueProb)
+			}
+		})
+}
+
+// softmaxModel is the saved form of SoftMaxRegression.
+type softmaxModel struct {
+	Weights    []param.Vector
+	Bias       []float64
+	FeatureLen int
+	LabelCount int
+	Precision  string `json:",omitempty"`
+}
+
+// Save writes the weights and bias as json, like LogisticRegression.Save.
+func (smr *SoftMaxRegression) Save(path string) error {
+	model := softmaxModel{Weights: smr.weights, Bias: smr.bias, FeatureLen: smr.featureLen, LabelCount: smr.labelCount}
+	if len(smr.weights) > 0 && smr.weights[0].Precision() == param.Float32 {
+		model.Precision = param.Float32
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadSoftmax reads a model written by SoftMaxRegression.Save.
+func LoadSoftmax(path string) (*SoftMaxRegression, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model := softmaxModel{}
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, err
+	}
+	if len(model.Weights) != model.LabelCount || len(model.Bias) != model.LabelCount {
+		return nil, fmt.Errorf("%d weight rows and %d biases for %d labels",
+			len(model.Weights), len(model.Bias), model.LabelCount)
+	}
+	for i := range model.Weights {
+		if model.Weights[i].Len() != model.FeatureLen {
+			return nil, fmt.Errorf("label %d has %d weights, want %d", i, model.Weights[i].Len(), model.FeatureLen)
+		}
+		model.Weights[i].Convert(model.Precision)
 	}
+	return &SoftMaxRegression{weights: model.Weights, bias: model.Bias,
+		featureLen: model.FeatureLen, labelCount: model.LabelCount}, nil
 }
@@ -0,0 +1,168 @@
+package allreduce
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	dialRetry    = 100 * time.Millisecond
+	dialAttempts = 100
+)
+
+// Ring connects every rank to rank+1, AllReduceSum then needs 2*(world-1)
+// steps each sending 1/world of the data, whatever the world size.
+type Ring struct {
+	rank  int
+	world int
+	next  net.Conn
+	prev  net.Conn
+	out   *bufio.Writer
+	in    *bufio.Reader
+}
+
+// NewRing listens on addrs[rank] and joins the ring formed by addrs.
+func NewRing(rank int, addrs []string) (*Ring, error) {
+	listener, err := net.Listen("tcp", addrs[rank])
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	return Connect(rank, len(addrs), listener, addrs[(rank+1)%len(addrs)])
+}
+
+// Connect dials the next rank and accepts the previous one on listener.
+func Connect(rank, world int, listener net.Listener, nextAddr string) (*Ring, error) {
+	r := &Ring{rank: rank, world: world}
+	if world == 1 {
+		return r, nil
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		r.prev = conn
+		accepted <- err
+	}()
+
+	var err error
+	for attempt := 0; attempt < dialAttempts; attempt++ {
+		if r.next, err = net.Dial("tcp", nextAddr); err == nil {
+			break
+		}
+		time.Sleep(dialRetry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rank %d dial %s: %s", rank, nextAddr, err.Error())
+	}
+	if err = <-accepted; err != nil {
+		r.next.Close()
+		return nil, err
+	}
+	r.out = bufio.NewWriter(r.next)
+	r.in = bufio.NewReader(r.prev)
+	return r, nil
+}
+
+func (r *Ring) Rank() int {
+	return r.rank
+}
+
+func (r *Ring) World() int {
+	return r.world
+}
+
+func (r *Ring) Close() error {
+	if r.world == 1 {
+		return nil
+	}
+	r.prev.Close()
+	return r.next.Close()
+}
+
+// chunk returns the part of data reduced by chunk index c.
+func (r *Ring) chunk(data []float64, c int) []float64 {
+	c = ((c % r.world) + r.world) % r.world
+	size := (len(data) + r.world - 1) / r.world
+	start := c * size
+	end := start + size
+	if start > len(data) {
+		start = len(data)
+	}
+	if end > len(data) {
+		end = len(data)
+	}
+	return data[start:end]
+}
+
+func (r *Ring) send(values []float64) error {
+	buf := make([]byte, 8)
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+		if _, err := r.out.Write(buf); err != nil {
+			return err
+		}
+	}
+	return r.out.Flush()
+}
+
+// recv reads len(values) floats, add sums them into values instead of
+// overwriting.
+func (r *Ring) recv(values []float64, add bool) error {
+	buf := make([]byte, 8)
+	for i := range values {
+		if _, err := io.ReadFull(r.in, buf); err != nil {
+			return err
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(buf))
+		if add {
+			values[i] += v
+		} else {
+			values[i] = v
+		}
+	}
+	return nil
+}
+
+// exchange sends one chunk while receiving another, so a full tcp buffer
+// on one side can not block the ring.
+func (r *Ring) exchange(sendChunk, recvChunk []float64, add bool) error {
+	// the receiving chunk is never the one being sent within a step
+	sent := make(chan error, 1)
+	go func() {
+		sent <- r.send(sendChunk)
+	}()
+	recvErr := r.recv(recvChunk, add)
+	if err := <-sent; err != nil {
+		return err
+	}
+	return recvErr
+}
+
+// AllReduceSum replaces data on every rank by the element wise sum over all
+// ranks. Every rank must call it with the same length.
+func (r *Ring) AllReduceSum(data []float64) error {
+	if r.world == 1 {
+		return nil
+	}
+	// reduce scatter: after it rank owns the full sum of chunk rank+1
+	for step := 0; step < r.world-1; step++ {
+		err := r.exchange(r.chunk(data, r.rank-step), r.chunk(data, r.rank-step-1), true)
+		if err != nil {
+			return err
+		}
+	}
+	// all gather: pass the reduced chunks around the ring
+	for step := 0; step < r.world-1; step++ {
+		err := r.exchange(r.chunk(data, r.rank+1-step), r.chunk(data, r.rank-step), false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package allreduce
+
+import (
+	"math"
+	"net"
+	"sync"
+	"testing"
+)
+
+// localRings builds a ring of world ranks on localhost ports.
+func localRings(t *testing.T, world int) []*Ring {
+	listeners := make([]net.Listener, world)
+	for i := range listeners {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = listener
+	}
+	rings := make([]*Ring, world)
+	wg := sync.WaitGroup{}
+	for i := 0; i < world; i++ {
+		wg.Add(1)
+		go func(rank int) {
+			defer wg.Done()
+			defer listeners[rank].Close()
+			ring, err := Connect(rank, world, listeners[rank], listeners[(rank+1)%world].Addr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rings[rank] = ring
+		}(i)
+	}
+	wg.Wait()
+	return rings
+}
+
+func TestAllReduceSum(t *testing.T) {
+	for world := 1; world <= 5; world++ {
+		rings := localRings(t, world)
+		for _, length := range []int{1, 3, 7, 100} {
+			data := make([][]float64, world)
+			expected := make([]float64, length)
+			for rank := range data {
+				data[rank] = make([]float64, length)
+				for i := range data[rank] {
+					data[rank][i] = float64(rank*1000+i) / 7
+					expected[i] += data[rank][i]
+				}
+			}
+
+			wg := sync.WaitGroup{}
+			for rank := 0; rank < world; rank++ {
+				wg.Add(1)
+				go func(rank int) {
+					defer wg.Done()
+					if err := rings[rank].AllReduceSum(data[rank]); err != nil {
+						t.Error(err)
+					}
+				}(rank)
+			}
+			wg.Wait()
+
+			for rank := range data {
+				for i, v := range data[rank] {
+					if math.Abs(v-expected[i]) > 1e-9 {
+						t.Fatalf("world %d length %d rank %d index %d: got %f want %f",
+							world, length, rank, i, v, expected[i])
+					}
+					if v != data[0][i] {
+						t.Fatalf("world %d rank %d differs from rank 0 at %d", world, rank, i)
+					}
+				}
+			}
+		}
+		for _, ring := range rings {
+			ring.Close()
+		}
+	}
+}
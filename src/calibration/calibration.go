@@ -0,0 +1,224 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	Platt    string = "platt"
+	Isotonic string = "isotonic"
+
+	epsilon float64 = 1e-12
+)
+
+// Calibrator maps a raw probability to a calibrated one. It is stored as
+// part of the model file, so only exported fields carry state.
+type Calibrator struct {
+	Method string `json:"method"`
+
+	// platt: p = 1 / (1 + exp(A * logit(raw) + B))
+	A float64 `json:"a,omitempty"`
+	B float64 `json:"b,omitempty"`
+
+	// isotonic: non decreasing step points, linear interpolation between them
+	X []float64 `json:"x,omitempty"`
+	Y []float64 `json:"y,omitempty"`
+}
+
+func Fit(method string, scores []float64, labels []int) (*Calibrator, error) {
+	if len(scores) != len(labels) {
+		return nil, fmt.Errorf("%d scores but %d labels", len(scores), len(labels))
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("empty calibration set")
+	}
+	switch method {
+	case Platt:
+		return FitPlatt(scores, labels), nil
+	case Isotonic:
+		return FitIsotonic(scores, labels), nil
+	}
+	return nil, fmt.Errorf("unknown calibration method %q", method)
+}
+
+func logit(p float64) float64 {
+	p = math.Min(math.Max(p, epsilon), 1-epsilon)
+	return math.Log(p / (1 - p))
+}
+
+// FitPlatt fits the sigmoid with Newton's method and the smoothed targets
+// from Platt 1999, following the implementation notes of Lin, Lin and Weng 2007.
+func FitPlatt(scores []float64, labels []int) *Calibrator {
+	prior1, prior0 := 0.0, 0.0
+	for _, y := range labels {
+		if y == 1 {
+			prior1++
+		} else {
+			prior0++
+		}
+	}
+	hiTarget := (prior1 + 1) / (prior1 + 2)
+	loTarget := 1 / (prior0 + 2)
+
+	n := len(scores)
+	f := make([]float64, n)
+	t := make([]float64, n)
+	for i := 0; i < n; i++ {
+		f[i] = logit(scores[i])
+		if labels[i] == 1 {
+			t[i] = hiTarget
+		} else {
+			t[i] = loTarget
+		}
+	}
+
+	objective := func(a, b float64) float64 {
+		fval := 0.0
+		for i := 0; i < n; i++ {
+			fApB := f[i]*a + b
+			if fApB >= 0 {
+				fval += t[i]*fApB + math.Log(1+math.Exp(-fApB))
+			} else {
+				fval += (t[i]-1)*fApB + math.Log(1+math.Exp(fApB))
+			}
+		}
+		return fval
+	}
+
+	a, b := 0.0, math.Log((prior0+1)/(prior1+1))
+	fval := objective(a, b)
+	const (
+		maxIter = 100
+		minStep = 1e-10
+		sigma   = 1e-12
+	)
+	for it := 0; it < maxIter; it++ {
+		h11, h22, h21, g1, g2 := sigma, sigma, 0.0, 0.0, 0.0
+		for i := 0; i < n; i++ {
+			fApB := f[i]*a + b
+			var p, q float64
+			if fApB >= 0 {
+				p = math.Exp(-fApB) / (1 + math.Exp(-fApB))
+				q = 1 / (1 + math.Exp(-fApB))
+			} else {
+				p = 1 / (1 + math.Exp(fApB))
+				q = math.Exp(fApB) / (1 + math.Exp(fApB))
+			}
+			d2 := p * q
+			h11 += f[i] * f[i] * d2
+			h22 += d2
+			h21 += f[i] * d2
+			d1 := t[i] - p
+			g1 += f[i] * d1
+			g2 += d1
+		}
+		if math.Abs(g1) < 1e-5 && math.Abs(g2) < 1e-5 {
+			break
+		}
+
+		det := h11*h22 - h21*h21
+		dA := -(h22*g1 - h21*g2) / det
+		dB := -(-h21*g1 + h11*g2) / det
+		gd := g1*dA + g2*dB
+
+		step := 1.0
+		for step >= minStep {
+			newA, newB := a+step*dA, b+step*dB
+			newF := objective(newA, newB)
+			if newF < fval+0.0001*step*gd {
+				a, b, fval = newA, newB, newF
+				break
+			}
+			step /= 2
+		}
+		if step < minStep {
+			break
+		}
+	}
+	return &Calibrator{Method: Platt, A: a, B: b}
+}
+
+// FitIsotonic runs pool adjacent violators over the scores sorted ascending.
+func FitIsotonic(scores []float64, labels []int) *Calibrator {
+	n := len(scores)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] < scores[order[j]] })
+
+	// every block keeps its label mean, weight and score range
+	type block struct {
+		sum, weight float64
+		low, high   float64
+	}
+	var blocks []block
+	for _, i := range order {
+		s := scores[i]
+		if len(blocks) > 0 && blocks[len(blocks)-1].high == s {
+			blocks[len(blocks)-1].sum += float64(labels[i])
+			blocks[len(blocks)-1].weight++
+		} else {
+			blocks = append(blocks, block{sum: float64(labels[i]), weight: 1, low: s, high: s})
+		}
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sum/prev.weight < last.sum/last.weight {
+				break
+			}
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{
+				sum:    prev.sum + last.sum,
+				weight: prev.weight + last.weight,
+				low:    prev.low,
+				high:   last.high,
+			}
+		}
+	}
+
+	c := &Calibrator{Method: Isotonic}
+	for _, b := range blocks {
+		mean := b.sum / b.weight
+		c.X = append(c.X, b.low)
+		c.Y = append(c.Y, mean)
+		if b.high != b.low {
+			c.X = append(c.X, b.high)
+			c.Y = append(c.Y, mean)
+		}
+	}
+	return c
+}
+
+func (c *Calibrator) Calibrate(p float64) float64 {
+	if c == nil {
+		return p
+	}
+	switch c.Method {
+	case Platt:
+		return 1 / (1 + math.Exp(c.A*logit(p)+c.B))
+	case Isotonic:
+		return c.interpolate(p)
+	}
+	return p
+}
+
+func (c *Calibrator) interpolate(p float64) float64 {
+	n := len(c.X)
+	if n == 0 {
+		return p
+	}
+	if p <= c.X[0] {
+		return c.Y[0]
+	}
+	if p >= c.X[n-1] {
+		return c.Y[n-1]
+	}
+	i := sort.SearchFloat64s(c.X, p)
+	if c.X[i] == p {
+		return c.Y[i]
+	}
+	x0, x1, y0, y1 := c.X[i-1], c.X[i], c.Y[i-1], c.Y[i]
+	return y0 + (y1-y0)*(p-x0)/(x1-x0)
+}
@@ -0,0 +1,106 @@
+package calibration
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIsotonicHandExample(t *testing.T) {
+	scores := []float64{0.6, 0.1, 0.3, 0.2, 0.5, 0.4}
+	labels := []int{1, 0, 0, 1, 1, 0}
+	c := FitIsotonic(scores, labels)
+	// 0.2 and 0.3, then 0.4 violate and pool to 1/3, 0.5 and 0.6 pool to 1
+	wantX := []float64{0.1, 0.2, 0.4, 0.5, 0.6}
+	wantY := []float64{0, 1.0 / 3, 1.0 / 3, 1, 1}
+	if !reflect.DeepEqual(c.X, wantX) || !reflect.DeepEqual(c.Y, wantY) {
+		t.Fatalf("steps %v %v, want %v %v", c.X, c.Y, wantX, wantY)
+	}
+	for _, pair := range [][2]float64{{0.05, 0}, {0.3, 1.0 / 3}, {0.45, 2.0 / 3}, {0.9, 1}} {
+		if got := c.Calibrate(pair[0]); math.Abs(got-pair[1]) > 1e-12 {
+			t.Errorf("calibrate(%v) = %v, want %v", pair[0], got, pair[1])
+		}
+	}
+}
+
+func TestIsotonicMonotone(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	scores := make([]float64, 2000)
+	labels := make([]int, len(scores))
+	for i := range scores {
+		// ties on purpose, two decimals only
+		scores[i] = math.Floor(r.Float64()*100) / 100
+		if r.Float64() < scores[i] {
+			labels[i] = 1
+		}
+	}
+	c := FitIsotonic(scores, labels)
+	for i := 1; i < len(c.X); i++ {
+		if c.X[i] < c.X[i-1] || c.Y[i] < c.Y[i-1] {
+			t.Fatalf("step %d: (%v, %v) after (%v, %v)", i, c.X[i], c.Y[i], c.X[i-1], c.Y[i-1])
+		}
+	}
+	prev := -1.0
+	for p := 0.0; p <= 1; p += 0.001 {
+		if got := c.Calibrate(p); got < prev {
+			t.Fatalf("calibrate(%v) = %v below %v", p, got, prev)
+		} else {
+			prev = got
+		}
+	}
+}
+
+func TestPlattRecoversSigmoid(t *testing.T) {
+	const A, B = -2.0, 0.5
+	r := rand.New(rand.NewSource(2))
+	scores := make([]float64, 50000)
+	labels := make([]int, len(scores))
+	for i := range scores {
+		scores[i] = 0.01 + 0.98*r.Float64()
+		if r.Float64() < 1/(1+math.Exp(A*logit(scores[i])+B)) {
+			labels[i] = 1
+		}
+	}
+	c := FitPlatt(scores, labels)
+	if math.Abs(c.A-A) > 0.1 || math.Abs(c.B-B) > 0.1 {
+		t.Errorf("fitted A %v B %v, want %v %v", c.A, c.B, A, B)
+	}
+}
+
+func TestFitChecksInput(t *testing.T) {
+	if _, err := Fit(Platt, []float64{0.5}, nil); err == nil {
+		t.Error("no error for missing labels")
+	}
+	if _, err := Fit("beta", []float64{0.5}, []int{1}); err == nil {
+		t.Error("no error for an unknown method")
+	}
+	var c *Calibrator
+	if c.Calibrate(0.3) != 0.3 {
+		t.Error("nil calibrator changes the probability")
+	}
+}
+
+func TestEvaluateHandExample(t *testing.T) {
+	probs := []float64{0.1, 0.2, 0.7, 0.9}
+	labels := []int{0, 1, 1, 1}
+	r := Evaluate(probs, labels, 2)
+	// bin [0, 0.5) has mean prob 0.15 and one positive of two, bin [0.5, 1]
+	// mean prob 0.8 and two positives of two
+	want := Report{Count: 4, PosRate: 0.75, MeanProb: 0.475,
+		ECE: 0.5*0.35 + 0.5*0.2, MCE: 0.35,
+		Brier:   (0.01 + 0.64 + 0.09 + 0.01) / 4,
+		LogLoss: -(math.Log(0.9) + math.Log(0.2) + math.Log(0.7) + math.Log(0.9)) / 4}
+	for name, pair := range map[string][2]float64{
+		"pos rate": {r.PosRate, want.PosRate}, "mean prob": {r.MeanProb, want.MeanProb},
+		"ece": {r.ECE, want.ECE}, "mce": {r.MCE, want.MCE},
+		"brier": {r.Brier, want.Brier}, "logloss": {r.LogLoss, want.LogLoss},
+	} {
+		if math.Abs(pair[0]-pair[1]) > 1e-12 {
+			t.Errorf("%s %v, want %v", name, pair[0], pair[1])
+		}
+	}
+	if r.Count != 4 {
+		t.Errorf("count %d", r.Count)
+	}
+}
@@ -0,0 +1,77 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+)
+
+type Report struct {
+	Count    int
+	PosRate  float64
+	MeanProb float64
+	// expected calibration error over equal width bins
+	ECE     float64
+	MCE     float64
+	Brier   float64
+	LogLoss float64
+}
+
+func Evaluate(probs []float64, labels []int, bins int) Report {
+	if bins <= 0 {
+		bins = 10
+	}
+	r := Report{Count: len(probs)}
+	if r.Count == 0 {
+		return r
+	}
+
+	binSum := make([]float64, bins)
+	binPos := make([]float64, bins)
+	binCount := make([]float64, bins)
+	for i, p := range probs {
+		y := float64(labels[i])
+		r.PosRate += y
+		r.MeanProb += p
+		r.Brier += (p - y) * (p - y)
+		clipped := math.Min(math.Max(p, epsilon), 1-epsilon)
+		r.LogLoss -= y*math.Log(clipped) + (1-y)*math.Log(1-clipped)
+
+		bi := int(p * float64(bins))
+		if bi >= bins {
+			bi = bins - 1
+		}
+		if bi < 0 {
+			bi = 0
+		}
+		binSum[bi] += p
+		binPos[bi] += y
+		binCount[bi]++
+	}
+
+	n := float64(r.Count)
+	for bi := 0; bi < bins; bi++ {
+		if binCount[bi] == 0 {
+			continue
+		}
+		gap := math.Abs(binSum[bi]-binPos[bi]) / binCount[bi]
+		r.ECE += binCount[bi] / n * gap
+		r.MCE = math.Max(r.MCE, gap)
+	}
+	r.PosRate /= n
+	r.MeanProb /= n
+	r.Brier /= n
+	r.LogLoss /= n
+	return r
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"count %d, pos rate %.06f, mean prob %.06f, ece %.06f, mce %.06f, brier %.06f, logloss %.06f",
+		r.Count, r.PosRate, r.MeanProb, r.ECE, r.MCE, r.Brier, r.LogLoss)
+}
+
+// Fields lists the report as name, value pairs for structured logging.
+func (r Report) Fields() []interface{} {
+	return []interface{}{"count", r.Count, "pos_rate", r.PosRate, "mean_prob", r.MeanProb,
+		"ece", r.ECE, "mce", r.MCE, "brier", r.Brier, "logloss", r.LogLoss}
+}
@@ -6,19 +6,29 @@ import (
 	"strings"
 )
 
+const defaultConfPath = "./config/settings_dev_1.yml"
+
 var (
 	config = Config{}
+	// as read from file, before --log= is substituted
+	loaded = Config{}
 )
 
 type Config struct {
 	LogConf     LogConf   `yaml:"log"`
 	SoftmaxConf TrainConf `yaml:"softmax"`
 	LRConf      TrainConf `yaml:"lr"`
+	MaxEntConf  TrainConf `yaml:"maxent"`
 }
 
 type LogConf struct {
 	LogLevel string `yaml:"level"`
 	LogPath  string `yaml:"path"`
+	// rotate when the file passes MaxSize MB, keeping MaxBackups old files
+	MaxSize    int `yaml:"maxSize"`
+	MaxBackups int `yaml:"maxBackups"`
+	// Quiet stops echoing log lines to stdout when a file is set
+	Quiet bool `yaml:"quiet"`
 }
 
 type TrainConf struct {
@@ -27,9 +37,32 @@ type TrainConf struct {
 	FeatureLen   int     `yaml:"featureLen"`
 	OneBatch     int     `yaml:"onebatch"`
 	LearningRate float64 `yaml:"learningRate"`
-	Normal       string  `yaml:"normal"`
-	NormalRate   float64 `yaml:"normalRate"`
+	Normal       string  `yaml:"normal"`     // not applied by any trainer yet
+	NormalRate   float64 `yaml:"normalRate"` // not applied by any trainer yet
 	ModelPath    string  `yaml:"modelPath"`
+	BPointPath   string  `yaml:"bpoint"`
+	// file, shuffle, window, stratified or bootstrap
+	Order         string `yaml:"order"`
+	ShuffleWindow int    `yaml:"shuffleWindow"`
+	Seed          int64  `yaml:"seed"`
+	// platt or isotonic, fitted on CalibrationPath after training
+	Calibration     string `yaml:"calibration"`
+	CalibrationPath string `yaml:"calibrationPath"`
+	// online mode: rolling metric window size and seconds between snapshots
+	OnlineWindow    int `yaml:"onlineWindow"`
+	SnapshotSeconds int `yaml:"snapshotSeconds"`
+	// parameter server: weight shards and max clock gap between workers
+	Shards    int `yaml:"shards"`
+	Staleness int `yaml:"staleness"`
+	// softmax: 0 infers the label count from data, format is dense or libsvm
+	LabelCount int    `yaml:"labelCount"`
+	Format     string `yaml:"format"`
+	// weight storage, float64 or float32
+	Precision string `yaml:"precision"`
+	// metrics history csv or .jsonl, defaults to <modelPath>/<model>_history.csv;
+	// metricsAddr serves prometheus gauges on /metrics, e.g. ":9108"
+	History     string `yaml:"history"`
+	MetricsAddr string `yaml:"metricsAddr"`
 }
 
 func (logConf *LogConf) updateFileName(logName string) {
@@ -40,7 +73,7 @@ func (logConf *LogConf) updateFileName(logName string) {
 
 func init() {
 	// Default path
-	confPath := "./config/settings_dev_1.yml"
+	confPath := defaultConfPath
 	for i := 1; i < len(os.Args); i++ {
 		if hit := strings.HasPrefix(os.Args[i], "--conf="); hit {
 			confPath = os.Args[i][7:]
@@ -49,8 +82,13 @@ func init() {
 	}
 	err := config.LoadConfig(confPath)
 	if err != nil {
-		panic(err.Error())
+		// package tests run in their own directory without the default file
+		if confPath != defaultConfPath || !os.IsNotExist(err) {
+			panic(err.Error())
+		}
+		fmt.Println("no config found, use empty config")
 	}
+	loaded = config
 
 	logName := "app"
 	for i := 1; i < len(os.Args); i++ {
@@ -73,3 +111,12 @@ func GetLRConf() TrainConf {
 func GetSoftmaxConf() TrainConf {
 	return config.SoftmaxConf
 }
+
+func GetMaxEntConf() TrainConf {
+	return config.MaxEntConf
+}
+
+// GetConfig returns the whole config as read from file.
+func GetConfig() Config {
+	return loaded
+}
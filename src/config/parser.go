@@ -15,3 +15,33 @@ func (c *Config) LoadConfig(filePath string) error {
 
 	return err
 }
+
+func (c *Config) SaveConfig(filePath string) error {
+	data, err := yaml.Marshal(c)
+	if err == nil {
+		err = ioutil.WriteFile(filePath, data, 0644)
+	}
+	return err
+}
+
+// Override returns a copy of the conf with fields replaced by their yaml
+// key, e.g. {"learningRate": 0.1, "onebatch": 100}.
+func (t TrainConf) Override(params map[string]interface{}) (TrainConf, error) {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return t, err
+	}
+	fields := make(map[string]interface{})
+	if err = yaml.Unmarshal(data, &fields); err != nil {
+		return t, err
+	}
+	for key, value := range params {
+		fields[key] = value
+	}
+	if data, err = yaml.Marshal(fields); err != nil {
+		return t, err
+	}
+	result := TrainConf{}
+	err = yaml.UnmarshalStrict(data, &result)
+	return result, err
+}
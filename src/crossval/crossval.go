@@ -0,0 +1,144 @@
+package crossval
+
+import (
+	"fmt"
+	"logging"
+	"math"
+	"math/rand"
+	"metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Fold struct {
+	Train []int
+	Test  []int
+}
+
+// TrainFunc trains a fresh model on the train indexes of one fold and
+// returns its metrics on the test indexes.
+type TrainFunc func(fold int, train, test []int) metrics.Result
+
+type Summary struct {
+	Folds []metrics.Result
+	Mean  metrics.Result
+	Std   metrics.Result
+}
+
+// Split assigns n samples to k folds. With stratified every label is dealt
+// round robin over the folds, so each fold keeps the label proportion.
+func Split(n, k int, labels []int, stratified bool, seed int64) []Fold {
+	if k < 2 || k > n {
+		panic(fmt.Sprintf("can not split %d samples into %d folds", n, k))
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	foldOf := make([]int, n)
+	if stratified {
+		byLabel := make(map[int][]int)
+		var keys []int
+		for i := 0; i < n; i++ {
+			if _, ok := byLabel[labels[i]]; !ok {
+				keys = append(keys, labels[i])
+			}
+			byLabel[labels[i]] = append(byLabel[labels[i]], i)
+		}
+		sort.Ints(keys)
+		next := 0
+		for _, label := range keys {
+			indexes := byLabel[label]
+			r.Shuffle(len(indexes), func(i, j int) { indexes[i], indexes[j] = indexes[j], indexes[i] })
+			for _, index := range indexes {
+				foldOf[index] = next % k
+				next++
+			}
+		}
+	} else {
+		perm := r.Perm(n)
+		for i, index := range perm {
+			foldOf[index] = i % k
+		}
+	}
+
+	folds := make([]Fold, k)
+	for i := 0; i < n; i++ {
+		for f := 0; f < k; f++ {
+			if foldOf[i] == f {
+				folds[f].Test = append(folds[f].Test, i)
+			} else {
+				folds[f].Train = append(folds[f].Train, i)
+			}
+		}
+	}
+	return folds
+}
+
+// Run trains every fold in its own goroutine, at most parallel at a time.
+func Run(folds []Fold, parallel int, train TrainFunc) Summary {
+	if parallel <= 0 {
+		parallel = len(folds)
+	}
+	results := make([]metrics.Result, len(folds))
+	limit := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	for i := range folds {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(fi int) {
+			defer wg.Done()
+			defer func() { <-limit }()
+			start := time.Now()
+			results[fi] = train(fi, folds[fi].Train, folds[fi].Test)
+			logging.With(results[fi].Fields()...).Info("fold done", "fold", fi, "cost", time.Now().Sub(start))
+		}(i)
+	}
+	wg.Wait()
+	return Summarize(results)
+}
+
+// Summarize computes mean and sample standard deviation of every metric
+// that is present in all results.
+func Summarize(results []metrics.Result) Summary {
+	summary := Summary{Folds: results, Mean: metrics.Result{}, Std: metrics.Result{}}
+	if len(results) == 0 {
+		return summary
+	}
+	for _, name := range results[0].Names() {
+		var values []float64
+		for _, r := range results {
+			if v, ok := r[name]; ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) != len(results) {
+			continue
+		}
+		mean := 0.0
+		for _, v := range values {
+			mean += v
+		}
+		mean /= float64(len(values))
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		if len(values) > 1 {
+			variance /= float64(len(values) - 1)
+		}
+		summary.Mean[name] = mean
+		summary.Std[name] = math.Sqrt(variance)
+	}
+	return summary
+}
+
+func (s Summary) String() string {
+	result := ""
+	for _, name := range s.Mean.Names() {
+		result += fmt.Sprintf("%-10s mean %.06f std %.06f\n", name, s.Mean[name], s.Std[name])
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package crossval
+
+import (
+	"math"
+	"metrics"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSplitPartitions(t *testing.T) {
+	for _, stratified := range []bool{false, true} {
+		const n, k = 103, 5
+		labels := make([]int, n)
+		for i := range labels {
+			labels[i] = i % 3
+		}
+		folds := Split(n, k, labels, stratified, 1)
+		if len(folds) != k {
+			t.Fatalf("%d folds", len(folds))
+		}
+		var tested []int
+		for fi, fold := range folds {
+			if len(fold.Test) < n/k || len(fold.Test) > n/k+1 {
+				t.Errorf("fold %d tests %d samples", fi, len(fold.Test))
+			}
+			if len(fold.Train)+len(fold.Test) != n {
+				t.Errorf("fold %d: %d train and %d test of %d", fi, len(fold.Train), len(fold.Test), n)
+			}
+			inTest := make(map[int]bool)
+			for _, index := range fold.Test {
+				inTest[index] = true
+			}
+			for _, index := range fold.Train {
+				if inTest[index] {
+					t.Fatalf("fold %d trains and tests %d", fi, index)
+				}
+			}
+			tested = append(tested, fold.Test...)
+		}
+		sort.Ints(tested)
+		for i, index := range tested {
+			if index != i {
+				t.Fatalf("stratified %v: test sets do not partition 0..%d", stratified, n-1)
+			}
+		}
+	}
+}
+
+func TestStratifiedKeepsLabelRatio(t *testing.T) {
+	const n, k = 1000, 4
+	labels := make([]int, n)
+	for i := 0; i < 100; i++ {
+		labels[i] = 1
+	}
+	for _, fold := range Split(n, k, labels, true, 2) {
+		positives := 0
+		for _, index := range fold.Test {
+			positives += labels[index]
+		}
+		if positives != 25 {
+			t.Errorf("fold tests %d positives, want 25", positives)
+		}
+	}
+}
+
+func TestRunAndSummarize(t *testing.T) {
+	folds := Split(40, 4, nil, false, 3)
+	var running, maxRunning int32
+	summary := Run(folds, 2, func(fold int, train, test []int) metrics.Result {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&maxRunning)
+			if now <= old || atomic.CompareAndSwapInt32(&maxRunning, old, now) {
+				break
+			}
+		}
+		defer atomic.AddInt32(&running, -1)
+		result := metrics.Result{metrics.Accuracy: float64(fold)}
+		if fold > 0 {
+			result[metrics.LogLoss] = 1
+		}
+		return result
+	})
+	if maxRunning > 2 {
+		t.Errorf("%d folds ran at once, parallel 2", maxRunning)
+	}
+	// accuracy 0, 1, 2, 3 has mean 1.5 and sample std sqrt(5/3), logloss
+	// is missing in fold 0
+	if summary.Mean[metrics.Accuracy] != 1.5 || math.Abs(summary.Std[metrics.Accuracy]-math.Sqrt(5.0/3)) > 1e-12 {
+		t.Errorf("accuracy mean %v std %v", summary.Mean[metrics.Accuracy], summary.Std[metrics.Accuracy])
+	}
+	if _, ok := summary.Mean[metrics.LogLoss]; ok {
+		t.Error("summary of a metric missing in one fold")
+	}
+	for fi, result := range summary.Folds {
+		if result[metrics.Accuracy] != float64(fi) {
+			t.Errorf("fold %d result in place %v", fi, result[metrics.Accuracy])
+		}
+	}
+}
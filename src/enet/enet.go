@@ -0,0 +1,373 @@
+package enet
+
+import (
+	"LR"
+	"fmt"
+	"math"
+	"metrics"
+	"param"
+)
+
+const (
+	Binomial string = "binomial"
+	Gaussian string = "gaussian"
+
+	MSE string = "mse"
+
+	minWeight float64 = 1e-5
+)
+
+// Config follows glmnet: the penalty is
+// lambda * (alpha * |w|_1 + (1 - alpha) / 2 * |w|_2^2)
+type Config struct {
+	Family      string
+	Alpha       float64
+	NLambda     int
+	LambdaRatio float64
+	Tol         float64
+	MaxIter     int
+	FeatureLen  int
+}
+
+type PathPoint struct {
+	Lambda    float64
+	NonZero   int
+	Weights   map[int]float64
+	Bias      float64
+	TrainLoss float64
+	Metrics   metrics.Result
+}
+
+type column struct {
+	rows []int
+	vals []float64
+}
+
+type solver struct {
+	conf    Config
+	n       int
+	cols    []column
+	y       []float64
+	weights []float64
+	bias    float64
+	// linear predictor b + Xw of every row
+	eta []float64
+}
+
+func (c *Config) setDefault() {
+	if c.Family == "" {
+		c.Family = Binomial
+	}
+	if c.NLambda <= 0 {
+		c.NLambda = 50
+	}
+	if c.LambdaRatio <= 0 {
+		c.LambdaRatio = 1e-3
+	}
+	if c.Tol <= 0 {
+		c.Tol = 1e-7
+	}
+	if c.MaxIter <= 0 {
+		c.MaxIter = 1000
+	}
+}
+
+func newSolver(conf Config, items []LR.SparseTrainItem) *solver {
+	featureLen := conf.FeatureLen
+	for _, item := range items {
+		for k := range item.Features {
+			if k >= featureLen {
+				featureLen = k + 1
+			}
+		}
+	}
+	s := &solver{
+		conf:    conf,
+		n:       len(items),
+		cols:    make([]column, featureLen),
+		y:       make([]float64, len(items)),
+		weights: make([]float64, featureLen),
+		eta:     make([]float64, len(items)),
+	}
+	s.conf.FeatureLen = featureLen
+	for i, item := range items {
+		s.y[i] = float64(item.Label)
+		for k, v := range item.Features {
+			s.cols[k].rows = append(s.cols[k].rows, i)
+			s.cols[k].vals = append(s.cols[k].vals, v)
+		}
+	}
+	return s
+}
+
+func sigmoid(z float64) float64 {
+	return 1.0 / (1 + math.Exp(-z))
+}
+
+func softThreshold(z, gamma float64) float64 {
+	if z > gamma {
+		return z - gamma
+	}
+	if z < -gamma {
+		return z + gamma
+	}
+	return 0
+}
+
+// lambdaMax is the smallest lambda that keeps every weight at zero.
+func (s *solver) lambdaMax() float64 {
+	mean := 0.0
+	for _, y := range s.y {
+		mean += y
+	}
+	mean /= float64(s.n)
+
+	alpha := math.Max(s.conf.Alpha, 1e-3)
+	max := 0.0
+	for _, col := range s.cols {
+		dot := 0.0
+		for ri, row := range col.rows {
+			dot += col.vals[ri] * (s.y[row] - mean)
+		}
+		max = math.Max(max, math.Abs(dot)/float64(s.n))
+	}
+	return max / alpha
+}
+
+func (s *solver) initBias() {
+	mean := 0.0
+	for _, y := range s.y {
+		mean += y
+	}
+	mean /= float64(s.n)
+	if s.conf.Family == Binomial {
+		mean = math.Min(math.Max(mean, 1e-6), 1-1e-6)
+		s.bias = math.Log(mean / (1 - mean))
+	} else {
+		s.bias = mean
+	}
+	for i := range s.eta {
+		s.eta[i] = s.bias
+	}
+}
+
+// fit solves one lambda, warm started from the current weights. Gaussian is
+// a single weighted least squares problem with unit weights, binomial
+// repeats it on the quadratic approximation around the current predictor.
+func (s *solver) fit(lambda float64) {
+	n := float64(s.n)
+	q := make([]float64, s.n)
+	r := make([]float64, s.n)
+	v := make([]float64, len(s.cols))
+
+	outer := 1
+	if s.conf.Family == Binomial {
+		outer = 25
+	}
+	for oi := 0; oi < outer; oi++ {
+		for i := 0; i < s.n; i++ {
+			if s.conf.Family == Binomial {
+				p := sigmoid(s.eta[i])
+				q[i] = math.Max(p*(1-p), minWeight)
+				r[i] = (s.y[i] - p) / q[i]
+			} else {
+				q[i] = 1
+				r[i] = s.y[i] - s.eta[i]
+			}
+		}
+		for j, col := range s.cols {
+			v[j] = 0
+			for ri, row := range col.rows {
+				v[j] += q[row] * col.vals[ri] * col.vals[ri]
+			}
+			v[j] /= n
+		}
+
+		maxDelta := s.coordinateDescent(lambda, q, r, v)
+		if s.conf.Family != Binomial || maxDelta < s.conf.Tol {
+			break
+		}
+	}
+}
+
+// coordinateDescent runs full passes until the active set is stable and
+// the weights converge, returns the largest change of the first pass.
+func (s *solver) coordinateDescent(lambda float64, q, r, v []float64) float64 {
+	n := float64(s.n)
+	l1 := lambda * s.conf.Alpha
+	l2 := lambda * (1 - s.conf.Alpha)
+	sumQ := 0.0
+	for _, qi := range q {
+		sumQ += qi
+	}
+
+	update := func(j int) float64 {
+		col := s.cols[j]
+		if v[j] == 0 {
+			return 0
+		}
+		old := s.weights[j]
+		z := 0.0
+		for ri, row := range col.rows {
+			z += q[row] * col.vals[ri] * r[row]
+		}
+		z = z/n + v[j]*old
+		w := softThreshold(z, l1) / (v[j] + l2)
+		if w == old {
+			return 0
+		}
+		delta := w - old
+		s.weights[j] = w
+		for ri, row := range col.rows {
+			r[row] -= col.vals[ri] * delta
+			s.eta[row] += col.vals[ri] * delta
+		}
+		return v[j] * delta * delta
+	}
+	updateBias := func() float64 {
+		sum := 0.0
+		for i, qi := range q {
+			sum += qi * r[i]
+		}
+		delta := sum / sumQ
+		s.bias += delta
+		for i := range r {
+			r[i] -= delta
+			s.eta[i] += delta
+		}
+		return delta * delta
+	}
+
+	firstDelta := -1.0
+	for it := 0; it < s.conf.MaxIter; it++ {
+		// full pass, also finds new active features
+		maxDelta := updateBias()
+		for j := range s.cols {
+			maxDelta = math.Max(maxDelta, update(j))
+		}
+		if firstDelta < 0 {
+			firstDelta = maxDelta
+		}
+		if maxDelta < s.conf.Tol {
+			break
+		}
+
+		var active []int
+		for j, w := range s.weights {
+			if w != 0 {
+				active = append(active, j)
+			}
+		}
+		for ai := 0; ai < s.conf.MaxIter; ai++ {
+			maxDelta = updateBias()
+			for _, j := range active {
+				maxDelta = math.Max(maxDelta, update(j))
+			}
+			if maxDelta < s.conf.Tol {
+				break
+			}
+		}
+	}
+	return firstDelta
+}
+
+func (s *solver) trainLoss() float64 {
+	loss := 0.0
+	for i, eta := range s.eta {
+		if s.conf.Family == Binomial {
+			p := math.Min(math.Max(sigmoid(eta), 1e-15), 1-1e-15)
+			loss -= s.y[i]*math.Log(p) + (1-s.y[i])*math.Log(1-p)
+		} else {
+			loss += (s.y[i] - eta) * (s.y[i] - eta)
+		}
+	}
+	return loss / float64(s.n)
+}
+
+func (s *solver) point(lambda float64) PathPoint {
+	point := PathPoint{Lambda: lambda, Weights: make(map[int]float64), Bias: s.bias}
+	for j, w := range s.weights {
+		if w != 0 {
+			point.Weights[j] = w
+		}
+	}
+	point.NonZero = len(point.Weights)
+	point.TrainLoss = s.trainLoss()
+	return point
+}
+
+// Path fits the whole decreasing lambda path on training with warm starts
+// and evaluates every point on validation.
+func Path(conf Config, training, validation []LR.SparseTrainItem) []PathPoint {
+	conf.setDefault()
+	if conf.Family != Binomial && conf.Family != Gaussian {
+		panic(fmt.Sprintf("unknown family %q", conf.Family))
+	}
+	s := newSolver(conf, training)
+	s.initBias()
+
+	lambdaMax := s.lambdaMax()
+	ratio := math.Pow(conf.LambdaRatio, 1/float64(conf.NLambda-1))
+	var path []PathPoint
+	lambda := lambdaMax
+	for li := 0; li < conf.NLambda; li++ {
+		s.fit(lambda)
+		point := s.point(lambda)
+		point.Metrics = point.Evaluate(s.conf, validation)
+		path = append(path, point)
+		lambda *= ratio
+	}
+	return path
+}
+
+// Model converts a path point into the LR model format, which applies a
+// sigmoid, so only binomial points make sense as a LR model.
+func (p PathPoint) Model(featureLen int) *LR.LogisticRegression {
+	for k := range p.Weights {
+		if k >= featureLen {
+			featureLen = k + 1
+		}
+	}
+	weights := make([]float64, featureLen)
+	for k, w := range p.Weights {
+		weights[k] = w
+	}
+	return &LR.LogisticRegression{
+		Weights:    param.FromFloat64s(weights),
+		Bias:       p.Bias,
+		FeatureLen: featureLen,
+	}
+}
+
+// predict is the linear predictor of item, features the path never weighted,
+// e.g. indexes beyond the training data, count as zero.
+func (p PathPoint) predict(item *LR.SparseTrainItem) float64 {
+	pred := p.Bias
+	for k, v := range item.Features {
+		if w, ok := p.Weights[k]; ok {
+			pred += w * v
+		}
+	}
+	return pred
+}
+
+func (p PathPoint) Evaluate(conf Config, items []LR.SparseTrainItem) metrics.Result {
+	if len(items) == 0 {
+		return metrics.Result{}
+	}
+	if conf.Family == Binomial {
+		return metrics.Parallel(len(items), 0, metrics.NewBinaryAccumulator,
+			func(acc metrics.Accumulator, start, end int) {
+				binary := acc.(*metrics.BinaryAccumulator)
+				for i := start; i < end; i++ {
+					binary.Add(sigmoid(p.predict(&items[i])), items[i].Label)
+				}
+			})
+	}
+	mse := 0.0
+	for i := range items {
+		residual := float64(items[i].Label) - p.predict(&items[i])
+		mse += residual * residual
+	}
+	return metrics.Result{MSE: mse / float64(len(items))}
+}
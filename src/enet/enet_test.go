@@ -0,0 +1,147 @@
+package enet
+
+import (
+	"LR"
+	"math"
+	"math/rand"
+	"metrics"
+	"testing"
+)
+
+// linear draws y = round(1 + 2 x0 - x1 + noise), x2 only on some rows.
+func linear(n int, seed int64) []LR.SparseTrainItem {
+	r := rand.New(rand.NewSource(seed))
+	items := make([]LR.SparseTrainItem, n)
+	for i := range items {
+		x0, x1 := r.NormFloat64(), r.NormFloat64()
+		fs := map[int]float64{0: x0, 1: x1}
+		if i%3 == 0 {
+			fs[2] = r.Float64()
+		}
+		items[i] = LR.SparseTrainItem{Label: int(math.Round(1 + 2*x0 - x1 + r.NormFloat64())), Features: fs}
+	}
+	return items
+}
+
+func fitted(conf Config, items []LR.SparseTrainItem, lambda float64) *solver {
+	conf.setDefault()
+	conf.Tol = 1e-14
+	s := newSolver(conf, items)
+	s.initBias()
+	s.fit(lambda)
+	return s
+}
+
+func TestLambdaMaxZeroesWeights(t *testing.T) {
+	for _, family := range []string{Gaussian, Binomial} {
+		items := linear(300, 1)
+		if family == Binomial {
+			for i := range items {
+				if items[i].Label > 1 {
+					items[i].Label = 1
+				} else {
+					items[i].Label = 0
+				}
+			}
+		}
+		conf := Config{Family: family, Alpha: 0.7}
+		conf.setDefault()
+		lambdaMax := newSolver(conf, items).lambdaMax()
+		for j, w := range fitted(conf, items, lambdaMax).weights {
+			if w != 0 {
+				t.Errorf("%s: weight %d = %v at lambda max", family, j, w)
+			}
+		}
+		nonZero := 0
+		for _, w := range fitted(conf, items, 0.95*lambdaMax).weights {
+			if w != 0 {
+				nonZero++
+			}
+		}
+		if nonZero == 0 {
+			t.Errorf("%s: no weight below lambda max", family)
+		}
+	}
+}
+
+// centered returns the centered columns of features 0 and 1 and the
+// centered labels.
+func centered(items []LR.SparseTrainItem) (x [2][]float64, y []float64) {
+	n := float64(len(items))
+	var mx [2]float64
+	my := 0.0
+	for _, item := range items {
+		mx[0] += item.Features[0] / n
+		mx[1] += item.Features[1] / n
+		my += float64(item.Label) / n
+	}
+	for _, item := range items {
+		x[0] = append(x[0], item.Features[0]-mx[0])
+		x[1] = append(x[1], item.Features[1]-mx[1])
+		y = append(y, float64(item.Label)-my)
+	}
+	return x, y
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func TestRidgeClosedForm(t *testing.T) {
+	items := linear(200, 2)
+	for i := range items {
+		delete(items[i].Features, 2)
+	}
+	const lambda = 0.3
+	s := fitted(Config{Family: Gaussian, Alpha: 0}, items, lambda)
+
+	// (X'X / n + lambda I) w = X'y / n over centered data
+	x, y := centered(items)
+	n := float64(len(items))
+	a, b, d := dot(x[0], x[0])/n+lambda, dot(x[0], x[1])/n, dot(x[1], x[1])/n+lambda
+	c0, c1 := dot(x[0], y)/n, dot(x[1], y)/n
+	det := a*d - b*b
+	want := []float64{(d*c0 - b*c1) / det, (a*c1 - b*c0) / det}
+	for j := range want {
+		if math.Abs(s.weights[j]-want[j]) > 1e-6 {
+			t.Errorf("ridge weight %d = %v, want %v", j, s.weights[j], want[j])
+		}
+	}
+}
+
+func TestLassoClosedForm(t *testing.T) {
+	items := linear(200, 3)
+	for i := range items {
+		items[i].Features = map[int]float64{0: items[i].Features[0]}
+	}
+	const lambda = 0.5
+	s := fitted(Config{Family: Gaussian, Alpha: 1}, items, lambda)
+
+	// one feature: w = S(x'y / n, lambda) / (x'x / n)
+	x, y := centered(items)
+	n := float64(len(items))
+	want := softThreshold(dot(x[0], y)/n, lambda) / (dot(x[0], x[0]) / n)
+	if math.Abs(s.weights[0]-want) > 1e-6 {
+		t.Errorf("lasso weight %v, want %v", s.weights[0], want)
+	}
+}
+
+func TestEvaluateIgnoresUnknownFeatures(t *testing.T) {
+	point := PathPoint{Weights: map[int]float64{0: 1, 1: -1}, Bias: 0.5}
+	items := []LR.SparseTrainItem{
+		{Label: 1, Features: map[int]float64{0: 1, 99: 5}},
+		{Label: 0, Features: map[int]float64{1: 1, 1000: 1}},
+	}
+	binomial := point.Evaluate(Config{Family: Binomial, FeatureLen: 2}, items)
+	if binomial[metrics.Accuracy] != 1 {
+		t.Errorf("binomial %v", binomial)
+	}
+	// predictions 1.5 and -0.5
+	if mse := point.Evaluate(Config{Family: Gaussian, FeatureLen: 2}, items)[MSE]; math.Abs(mse-0.25) > 1e-12 {
+		t.Errorf("mse %v, want 0.25", mse)
+	}
+}
@@ -0,0 +1,274 @@
+// Package logging writes leveled `key=value` lines, configured by LogConf:
+//
+//	2019-03-22 15:04:05.000 INFO iter done iter=3 accuracy=0.912 cost=1.2s
+//
+// Before Init lines go to stdout at INFO.
+package logging
+
+import (
+	"config"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+const (
+	DefaultMaxSize    int = 100
+	DefaultMaxBackups int = 5
+	timeFormat            = "2006-01-02 15:04:05.000"
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	if l < DEBUG || l > ERROR {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return INFO, fmt.Errorf("unknown log level %q", name)
+}
+
+type Logger struct {
+	lock    sync.Mutex
+	level   Level
+	console io.Writer
+	file    *rotateFile
+}
+
+var std = &Logger{level: INFO, console: os.Stdout}
+
+// Init points the package logger to conf, LogPath empty only logs to stdout.
+func Init(conf config.LogConf) error {
+	logger, err := New(conf)
+	if err != nil {
+		return err
+	}
+	old := std
+	std = logger
+	return old.Close()
+}
+
+func New(conf config.LogConf) (*Logger, error) {
+	logger := &Logger{level: INFO, console: os.Stdout}
+	if conf.LogLevel != "" {
+		level, err := ParseLevel(conf.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		logger.level = level
+	}
+	if conf.LogPath != "" {
+		file, err := openRotate(conf.LogPath, conf.MaxSize, conf.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		logger.file = file
+		if conf.Quiet {
+			logger.console = nil
+		}
+	}
+	return logger, nil
+}
+
+func (l *Logger) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) log(level Level, msg string, fields []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	line := format(time.Now(), level, msg, fields)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.console != nil {
+		io.WriteString(l.console, line)
+	}
+	if l.file != nil {
+		if err := l.file.Write(line); err != nil {
+			fmt.Fprintln(os.Stderr, "write log:", err.Error())
+		}
+	}
+}
+
+// format renders fields as key=value pairs, a key without value is kept
+// under the key `extra`.
+func format(t time.Time, level Level, msg string, fields []interface{}) string {
+	b := strings.Builder{}
+	b.WriteString(t.Format(timeFormat))
+	b.WriteByte(' ')
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := "extra", fields[i]
+		if i+1 < len(fields) {
+			key, value = fmt.Sprint(fields[i]), fields[i+1]
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(formatValue(value))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func formatValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case float64:
+		s = strconv.FormatFloat(v, 'g', 6, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'g', 6, 32)
+	case time.Duration:
+		s = v.String()
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.log(DEBUG, msg, fields) }
+func (l *Logger) Info(msg string, fields ...interface{})  { l.log(INFO, msg, fields) }
+func (l *Logger) Warn(msg string, fields ...interface{})  { l.log(WARN, msg, fields) }
+func (l *Logger) Error(msg string, fields ...interface{}) { l.log(ERROR, msg, fields) }
+
+// With returns an entry that adds fields to every line.
+func (l *Logger) With(fields ...interface{}) *Entry {
+	return &Entry{logger: l, fields: fields}
+}
+
+type Entry struct {
+	logger *Logger
+	fields []interface{}
+}
+
+func (e *Entry) join(fields []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(e.fields)+len(fields))
+	all = append(all, fields...)
+	return append(all, e.fields...)
+}
+
+func (e *Entry) Debug(msg string, fields ...interface{}) { e.logger.log(DEBUG, msg, e.join(fields)) }
+func (e *Entry) Info(msg string, fields ...interface{})  { e.logger.log(INFO, msg, e.join(fields)) }
+func (e *Entry) Warn(msg string, fields ...interface{})  { e.logger.log(WARN, msg, e.join(fields)) }
+func (e *Entry) Error(msg string, fields ...interface{}) { e.logger.log(ERROR, msg, e.join(fields)) }
+
+func Debug(msg string, fields ...interface{}) { std.log(DEBUG, msg, fields) }
+func Info(msg string, fields ...interface{})  { std.log(INFO, msg, fields) }
+func Warn(msg string, fields ...interface{})  { std.log(WARN, msg, fields) }
+func Error(msg string, fields ...interface{}) { std.log(ERROR, msg, fields) }
+
+func With(fields ...interface{}) *Entry {
+	return std.With(fields...)
+}
+
+// rotateFile renames path to path.1, path.1 to path.2 and so on when the
+// next line would pass maxSize bytes.
+type rotateFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotate(path string, maxSizeMB, maxBackups int) (*rotateFile, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotateFile{path: path, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	return r, r.open()
+}
+
+func (r *rotateFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotateFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", r.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotateFile) Write(line string) error {
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := io.WriteString(r.file, line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotateFile) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	at := time.Date(2019, 3, 22, 15, 4, 5, 0, time.UTC)
+	line := format(at, INFO, "iter done", []interface{}{
+		"iter", 3, "accuracy", 0.91234567, "cost", 1200 * time.Millisecond,
+		"path", "a b", "err", errors.New("x=1"), "dangling"})
+	want := `2019-03-22 15:04:05.000 INFO iter done iter=3 accuracy=0.912346 cost=1.2s ` +
+		`path="a b" err="x=1" extra=dangling` + "\n"
+	if line != want {
+		t.Errorf("got  %q\nwant %q", line, want)
+	}
+}
+
+func TestLevelAndWith(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := &Logger{level: WARN, console: buf}
+	logger.Info("dropped")
+	logger.With("rank", 0).Warn("kept", "iter", 1)
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.HasSuffix(got, "WARN kept iter=1 rank=0\n") {
+		t.Errorf("unexpected output %q", got)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "app.log")
+	r, err := openRotate(path, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.maxSize = 10
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+
+	expect := map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"}
+	for p, content := range expect {
+		data, err := ioutil.ReadFile(p)
+		if err != nil || string(data) != content {
+			t.Errorf("%s: %q %v, want %q", p, data, err, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 backups kept")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// argString looks up `--name=value` the same way config does for --conf=.
+func argString(args []string, name, defaultValue string) string {
+	prefix := "--" + name + "="
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return arg[len(prefix):]
+		}
+	}
+	return defaultValue
+}
+
+func argInt(args []string, name string, defaultValue int) int {
+	if value, err := strconv.Atoi(argString(args, name, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func argFloat(args []string, name string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(argString(args, name, ""), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func argBool(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--"+name || arg == "--"+name+"=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// childArgs passes --conf= to a child process and gives it a log file of its
+// own, rotating one file from several processes would lose lines.
+func childArgs(suffix string) []string {
+	var passed []string
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "--conf=") {
+			passed = append(passed, arg)
+		}
+	}
+	return append(passed, "--log="+argString(os.Args[1:], "log", "app")+suffix)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"LR"
+	"fmt"
+	"logging"
+	"os"
+)
+
+// calibrate fits a calibrator for a trained LR model on a held out file and
+// stores it inside the model, e.g.
+// `calibrate --model=../resource/1553269965.model --data=../resource/ctr_valid.csv --method=isotonic`
+func calibrate(args []string) {
+	modelPath := argString(args, "model", "")
+	dataPath := argString(args, "data", "")
+	if modelPath == "" || dataPath == "" {
+		fmt.Println("usage: calibrate --model=<path> --data=<path> " +
+			"[--method=platt|isotonic] [--bins=10] [--out=<path>]")
+		os.Exit(1)
+	}
+
+	model, err := LR.LoadModel(modelPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	heldOut, err := LR.LoadSparseData(dataPath)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	before, after, err := model.Calibrate(
+		argString(args, "method", "platt"), heldOut, argInt(args, "bins", 10))
+	if err != nil {
+		panic(err.Error())
+	}
+	logging.With(before.Fields()...).Info("before calibration")
+	logging.With(after.Fields()...).Info("after calibration")
+
+	outPath := argString(args, "out", modelPath)
+	if err := model.Save(outPath); err != nil {
+		panic(err.Error())
+	}
+	logging.Info("model saved", "path", outPath)
+}
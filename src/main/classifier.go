@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"logging"
+	"maxent/IIS"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+	"strings"
+)
+
+// classifier trains the generic maxent classifier on `label pred pred ...`
+// lines, or labels such lines with a saved model, e.g.
+// `classifier --train=ner.train --test=ner.test --cutoff=2 --out=ner.model`
+// `classifier --model=ner.model --input=ner.test`
+func classifier(args []string) {
+	if modelPath := argString(args, "model", ""); modelPath != "" {
+		model, err := IIS.LoadClassifier(modelPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		events, err := data.ReadEvents(argString(args, "input", ""))
+		if err != nil {
+			panic(err.Error())
+		}
+		labels := model.Labels()
+		for _, event := range events {
+			probs := model.Probabilities(event)
+			best := 0
+			for li, p := range probs {
+				if p > probs[best] {
+					best = li
+				}
+			}
+			fmt.Printf("%s\t%.06f\t%s\n", labels[best], probs[best], strings.Join(event.Predicates, " "))
+		}
+		logging.With(model.Evaluate(events).Fields()...).Info("labelled", "events", len(events))
+		return
+	}
+
+	trainPath := argString(args, "train", "")
+	if trainPath == "" {
+		fmt.Println("usage: classifier --train=<path> [--test=<path>] [--iter=100] [--cutoff=1] " +
+			"[--solver=iis|gis|lbfgs] [--variance=0] [--precision=float64] [--history=<path>] [--out=<path>] | classifier --model=<path> --input=<path>")
+		os.Exit(1)
+	}
+	training, err := data.ReadEvents(trainPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	var testing []data.Event
+	if testPath := argString(args, "test", ""); testPath != "" {
+		if testing, err = data.ReadEvents(testPath); err != nil {
+			panic(err.Error())
+		}
+	}
+	recorder, err := metrics.NewRecorder("classifier", argString(args, "history", "./resource/classifier_history.csv"), "")
+	if err != nil {
+		panic(err.Error())
+	}
+	defer recorder.Close()
+	model := &IIS.Classifier{Cutoff: argInt(args, "cutoff", 1), Precision: argString(args, "precision", ""),
+		Solver: argString(args, "solver", ""), Variance: argFloat(args, "variance", 0), Recorder: recorder}
+	if err := model.Train(training, testing, argInt(args, "iter", 100)); err != nil {
+		panic(err.Error())
+	}
+	if outPath := argString(args, "out", ""); outPath != "" {
+		if err := model.Save(outPath); err != nil {
+			panic(err.Error())
+		}
+		logging.Info("model saved", "path", outPath)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"LR"
+	"config"
+	"crossval"
+	"fmt"
+	"maxent/IIS"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+)
+
+// cv runs k fold cross validation of one trainer over a single data file, e.g.
+// `cv --model=lr --k=5 --stratified --iter=10 --parallel=5`
+// `cv --model=maxent --solver=lbfgs --cores=4 --cutoff=5 --variance=1` picks
+// the maxent solver, the workers of its E-step, the minimum feature count
+// and the variance of the Gaussian prior.
+// the data file defaults to the train path of the trainer's config section.
+func cv(args []string) {
+	modelType := argString(args, "model", "lr")
+	k := argInt(args, "k", 5)
+	iter := argInt(args, "iter", 10)
+	parallel := argInt(args, "parallel", k)
+	stratified := argBool(args, "stratified")
+	seed := int64(argInt(args, "seed", 0))
+
+	var labels []int
+	var train crossval.TrainFunc
+	switch modelType {
+	case "lr":
+		conf := config.GetLRConf()
+		items, err := LR.LoadSparseData(argString(args, "data", conf.TrainPath))
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		train = func(fold int, trainIndex, testIndex []int) metrics.Result {
+			training, testing := pickSparse(items, trainIndex), pickSparse(items, testIndex)
+			model := &LR.LogisticRegression{}
+			model.Fit(conf, training, testing, iter)
+			return model.Evaluate(testing)
+		}
+	case "softmax":
+		conf := config.GetSoftmaxConf()
+		items, err := LR.LoadSoftmaxData(conf, argString(args, "data", conf.TrainPath))
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		train = func(fold int, trainIndex, testIndex []int) metrics.Result {
+			training, testing := pickDense(items, trainIndex), pickDense(items, testIndex)
+			model := &LR.SoftMaxRegression{}
+			model.Fit(conf, training, testing, iter)
+			return model.Evaluate(testing)
+		}
+	case "maxent":
+		conf := config.GetMaxEntConf()
+		rows, yCount, err := data.ReadMnistPixels(argString(args, "data", conf.TrainPath))
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, row := range rows {
+			labels = append(labels, row.Label)
+		}
+		train = func(fold int, trainIndex, testIndex []int) metrics.Result {
+			training, testing := pickMnist(rows, trainIndex), pickMnist(rows, testIndex)
+			// quantile cuts come from the training folds only
+			discretizer := fitDiscretizer(args, training)
+			model := &IIS.MaxEntIIS{Solver: argString(args, "solver", ""),
+				Cutoff: argInt(args, "cutoff", 0), Variance: argFloat(args, "variance", 0),
+				Tolerance: argFloat(args, "tolerance", 0), Discretizer: discretizer}
+			test := discretizer.Samples(testing)
+			if err := model.SetData(discretizer.Samples(training), test, yCount); err != nil {
+				panic(err.Error())
+			}
+			model.StartTraining(iter, argInt(args, "cores", 1))
+			return model.Evaluate(test)
+		}
+	default:
+		fmt.Println("usage: cv --model=lr|softmax|maxent [--data=<path>] [--k=5] " +
+			"[--stratified] [--iter=10] [--parallel=k] [--seed=0] [--solver=iis|gis|lbfgs] [--cores=1] [--cutoff=0] [--variance=0] [--tolerance=0] " +
+			"[--discretize=threshold|width|quantile|raw] [--bins=4] [--thresholds=128]")
+		os.Exit(1)
+	}
+
+	folds := crossval.Split(len(labels), k, labels, stratified, seed)
+	summary := crossval.Run(folds, parallel, train)
+	fmt.Printf("%d fold cross validation of %s\n%s", k, modelType, summary.String())
+}
+
+func pickSparse(items []LR.SparseTrainItem, indexes []int) []LR.SparseTrainItem {
+	result := make([]LR.SparseTrainItem, len(indexes))
+	for i, index := range indexes {
+		result[i] = items[index]
+	}
+	return result
+}
+
+func pickDense(items []LR.IndexTrainItem, indexes []int) []LR.IndexTrainItem {
+	result := make([]LR.IndexTrainItem, len(indexes))
+	for i, index := range indexes {
+		result[i] = items[index]
+	}
+	return result
+}
+
+func pickMnist(rows []data.PixelRow, indexes []int) []data.PixelRow {
+	result := make([]data.PixelRow, len(indexes))
+	for i, index := range indexes {
+		result[i] = rows[index]
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"LR"
+	"allreduce"
+	"config"
+	"fmt"
+	"logging"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dataParallel trains softmax on several local processes that average
+// gradients with ring allreduce every step, e.g.
+// `dp --world=4 --iter=10` launches 4 ranks on 127.0.0.1:9200-9203,
+// `dp --rank=<i> --addrs=host:port,host:port,...` runs a single rank.
+// Every rank ends with the same weights, rank 0 saves them to modelPath.
+func dataParallel(args []string) {
+	conf := config.GetSoftmaxConf()
+	iter := argInt(args, "iter", 10)
+	world := argInt(args, "world", 4)
+	var addrs []string
+	if list := argString(args, "addrs", ""); list != "" {
+		addrs = strings.Split(list, ",")
+	} else {
+		port := argInt(args, "port", 9200)
+		for i := 0; i < world; i++ {
+			addrs = append(addrs, fmt.Sprintf("127.0.0.1:%d", port+i))
+		}
+	}
+
+	rank := argInt(args, "rank", -1)
+	if rank < 0 {
+		launchRanks(args, addrs, iter)
+		return
+	}
+
+	ring, err := allreduce.NewRing(rank, addrs)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer ring.Close()
+
+	items, err := LR.LoadSoftmaxData(conf, conf.TrainPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	var testing []LR.IndexTrainItem
+	if rank == 0 {
+		if testing, err = LR.LoadSoftmaxData(conf, conf.TestPath); err != nil {
+			logging.Warn("load test data", "path", conf.TestPath, "err", err)
+		}
+	}
+	model := &LR.SoftMaxRegression{}
+	if rank == 0 {
+		model.Recorder = LR.NewRecorder("dp", conf)
+		defer model.Recorder.Close()
+	}
+	shard := LR.ShardDense(items, rank, len(addrs))
+	if err := model.FitDataParallel(conf, shard, testing, iter, ring); err != nil {
+		panic(err.Error())
+	}
+	if rank == 0 {
+		path := fmt.Sprintf("%s/%d.model", conf.ModelPath, time.Now().Unix())
+		if err := model.Save(path); err != nil {
+			panic(err.Error())
+		}
+		logging.Info("model saved", "path", path)
+	}
+}
+
+// launchRanks starts this binary once per rank and waits for all of them.
+func launchRanks(args []string, addrs []string, iter int) {
+	wg := sync.WaitGroup{}
+	for rank := range addrs {
+		rankArgs := append([]string{"dp",
+			fmt.Sprintf("--rank=%d", rank),
+			"--addrs=" + strings.Join(addrs, ","),
+			fmt.Sprintf("--iter=%d", iter)}, childArgs(fmt.Sprintf("-rank%d", rank))...)
+		cmd := exec.Command(os.Args[0], rankArgs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			panic(err.Error())
+		}
+		wg.Add(1)
+		go func(rank int) {
+			defer wg.Done()
+			if err := cmd.Wait(); err != nil {
+				logging.Error("rank exit", "rank", rank, "err", err)
+			}
+		}(rank)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"LR"
+	"config"
+	"enet"
+	"fmt"
+	"logging"
+	"math"
+	"metrics"
+	"os"
+	"time"
+)
+
+// elasticNet fits an elastic net path on the lr train path and reports
+// every lambda on the lr test path, e.g.
+// `enet --alpha=0.9 --nlambda=50 --metric=auc --path=../resource/enet_path.csv`
+// the lambda with the best metric, or --pick=<index>, is saved as a LR model.
+// Gaussian paths are only reported, the LR format would put a sigmoid on
+// their linear predictor.
+func elasticNet(args []string) {
+	conf := config.GetLRConf()
+	enetConf := enet.Config{
+		Family:      argString(args, "family", enet.Binomial),
+		Alpha:       argFloat(args, "alpha", 1),
+		NLambda:     argInt(args, "nlambda", 50),
+		LambdaRatio: argFloat(args, "ratio", 1e-3),
+		Tol:         argFloat(args, "tol", 1e-7),
+		FeatureLen:  conf.FeatureLen,
+	}
+	metric := metrics.AUCName
+	if enetConf.Family == enet.Gaussian {
+		metric = enet.MSE
+	}
+	metric = argString(args, "metric", metric)
+
+	training, err := LR.LoadSparseData(argString(args, "train", conf.TrainPath))
+	if err != nil {
+		panic(err.Error())
+	}
+	validation, err := LR.LoadSparseData(argString(args, "test", conf.TestPath))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	start := time.Now()
+	path := enet.Path(enetConf, training, validation)
+	cost := time.Now().Sub(start)
+	logging.Info("path done", "lambdas", len(path), "cost", cost)
+	recorder := LR.NewRecorder("enet", conf)
+	defer recorder.Close()
+
+	var csv *os.File
+	if csvPath := argString(args, "path", ""); csvPath != "" {
+		if csv, err = os.Create(csvPath); err != nil {
+			panic(err.Error())
+		}
+		defer csv.Close()
+		fmt.Fprintf(csv, "index,lambda,non_zero,train_loss,%s\n", metric)
+	}
+
+	lowerIsBetter := metric == metrics.LogLoss || metric == enet.MSE
+	best := -1
+	fmt.Printf("%5s %14s %8s %12s %12s\n", "index", "lambda", "nonzero", "train loss", metric)
+	for i, point := range path {
+		value := point.Metrics[metric]
+		fmt.Printf("%5d %14.08f %8d %12.06f %12.06f\n", i, point.Lambda, point.NonZero, point.TrainLoss, value)
+		if csv != nil {
+			fmt.Fprintf(csv, "%d,%g,%d,%g,%g\n", i, point.Lambda, point.NonZero, point.TrainLoss, value)
+		}
+		// one history row per lambda, the path cost is spread over them
+		result := metrics.Result{"lambda": point.Lambda, "non_zero": float64(point.NonZero)}
+		for name, v := range point.Metrics {
+			result[name] = v
+		}
+		recorder.Record(metrics.Record{Epoch: i, Step: i + 1, TrainLoss: point.TrainLoss,
+			Seconds: cost.Seconds() / float64(len(path)), Validation: result})
+		if math.IsNaN(value) {
+			continue
+		}
+		if best < 0 || (lowerIsBetter && value < path[best].Metrics[metric]) ||
+			(!lowerIsBetter && value > path[best].Metrics[metric]) {
+			best = i
+		}
+	}
+
+	pick := argInt(args, "pick", best)
+	if pick < 0 || pick >= len(path) {
+		logging.Warn("no lambda picked, model not saved")
+		return
+	}
+	point := path[pick]
+	if enetConf.Family != enet.Binomial {
+		logging.Warn("only binomial paths are saved as LR models, model not saved", "family", enetConf.Family,
+			"index", pick, "lambda", point.Lambda)
+		return
+	}
+	modelPath := argString(args, "out", fmt.Sprintf("%s/%d.model", conf.ModelPath, time.Now().Unix()))
+	if err := point.Model(conf.FeatureLen).Save(modelPath); err != nil {
+		panic(err.Error())
+	}
+	logging.Info("model saved", "path", modelPath, "index", pick, "lambda", point.Lambda,
+		"nonzero", point.NonZero, metric, point.Metrics[metric])
+}
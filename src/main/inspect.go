@@ -0,0 +1,53 @@
+package main
+
+import (
+	"LR"
+	"fmt"
+	"os"
+)
+
+// inspect prints top weights, sparsity, weight histogram and per field
+// importance of a LR model, e.g.
+// `inspect --model=../resource/1553269965.model --dict=features.txt --format=csv`
+func inspect(args []string) {
+	modelPath := argString(args, "model", "")
+	if modelPath == "" {
+		fmt.Println("usage: inspect --model=<path> [--dict=<path>] [--top=20] [--bins=20] " +
+			"[--fieldSep==] [--format=text|csv|json] [--out=<path>]")
+		os.Exit(1)
+	}
+
+	model, err := LR.LoadModel(modelPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	dict := map[int]string{}
+	if dictPath := argString(args, "dict", ""); dictPath != "" {
+		if dict, err = LR.LoadFeatureDict(dictPath); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	report := model.Report(dict,
+		argInt(args, "top", 20), argInt(args, "bins", 20), argString(args, "fieldSep", "="))
+
+	out := os.Stdout
+	if outPath := argString(args, "out", ""); outPath != "" {
+		if out, err = os.Create(outPath); err != nil {
+			panic(err.Error())
+		}
+		defer out.Close()
+	}
+
+	switch argString(args, "format", "text") {
+	case "csv":
+		err = report.WriteCSV(out)
+	case "json":
+		err = report.WriteJSON(out)
+	default:
+		err = report.WriteText(out)
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
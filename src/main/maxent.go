@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"logging"
+	"maxent/IIS"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxent trains MaxEntIIS on mnist csv files, or scores a csv file with a
+// saved model, e.g.
+// `maxent --iter=200 --solver=lbfgs --cores=4 --tolerance=1e-5 --out=./resource/maxent.model`
+// `maxent --model=./resource/maxent.model --input=./resource/Mnist/mnist_test.csv --topk=3`
+// prints the k best `label:prob` of every sample and its label in the file.
+// Pixels are binarized unless --discretize picks fixed --thresholds=64,128,192,
+// --bins equal width or quantile bins fit on the training file, or raw values,
+// scoring reads the input the way the model was trained.
+func maxent(args []string) {
+	if modelPath := argString(args, "model", ""); modelPath != "" {
+		model, err := IIS.LoadMaxEnt(modelPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		inputPath := argString(args, "input", "")
+		if inputPath == "" {
+			fmt.Println("usage: maxent --model=<path> --input=<csv> [--topk=1]")
+			os.Exit(1)
+		}
+		samples, _, err := data.ReadMnistCsvWith(inputPath, model.Discretizer)
+		if err != nil {
+			panic(err.Error())
+		}
+		k := argInt(args, "topk", 1)
+		for _, sample := range samples {
+			var top []string
+			for _, score := range model.TopK(sample.GetDataVec(), k) {
+				top = append(top, fmt.Sprintf("%d:%.06f", score.Label, score.Prob))
+			}
+			fmt.Printf("%s\t%d\n", strings.Join(top, " "), sample.GetLabel())
+		}
+		logging.With(model.Evaluate(samples).Fields()...).Info("scored", "samples", len(samples))
+		return
+	}
+
+	trainPath := argString(args, "train", "./resource/Mnist/mnist_train.csv")
+	testPath := argString(args, "test", "./resource/Mnist/mnist_test.csv")
+	trainRows, yCount, err := data.ReadMnistPixels(trainPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	testRows, testCount, err := data.ReadMnistPixels(testPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	if testCount != yCount {
+		panic("output label not equal between training and test set")
+	}
+	discretizer := fitDiscretizer(args, trainRows)
+	recorder, err := metrics.NewRecorder("maxent", argString(args, "history", "./resource/maxent_history.csv"), "")
+	if err != nil {
+		panic(err.Error())
+	}
+	defer recorder.Close()
+
+	model := IIS.MaxEntIIS{Solver: argString(args, "solver", ""), Variance: argFloat(args, "variance", 0),
+		Cutoff: argInt(args, "cutoff", 0), Tolerance: argFloat(args, "tolerance", 0),
+		Precision: argString(args, "precision", ""), Recorder: recorder, Discretizer: discretizer}
+	if err := model.SetData(discretizer.Samples(trainRows), discretizer.Samples(testRows), yCount); err != nil {
+		panic(err.Error())
+	}
+	model.StartTraining(argInt(args, "iter", 1500), argInt(args, "cores", 1))
+	if outPath := argString(args, "out", ""); outPath != "" {
+		if err := model.Save(outPath); err != nil {
+			panic(err.Error())
+		}
+		logging.Info("model saved", "path", outPath)
+	}
+}
+
+// fitDiscretizer builds the discretization of --discretize, --bins and
+// --thresholds and fits it on the training rows.
+func fitDiscretizer(args []string, rows []data.PixelRow) data.Discretizer {
+	var thresholds []float64
+	if list := argString(args, "thresholds", ""); list != "" {
+		for _, item := range strings.Split(list, ",") {
+			threshold, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				panic(err.Error())
+			}
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	discretizer, err := data.NewDiscretizer(argString(args, "discretize", data.DiscretizeThreshold),
+		argInt(args, "bins", 4), thresholds)
+	if err != nil {
+		panic(err.Error())
+	}
+	discretizer.Fit(rows)
+	logging.Info("pixels discretized", "strategy", discretizer.Strategy, "levels", discretizer.Levels())
+	return discretizer
+}
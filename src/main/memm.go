@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"logging"
+	"maxent/IIS"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+	"strings"
+)
+
+// memm trains a maxent Markov model tagger on CoNLL files, or tags a CoNLL
+// file with a saved model, e.g.
+// `memm --train=ner.train --test=ner.test --cutoff=2 --solver=lbfgs --out=ner.memm`
+// `memm --model=ner.memm --input=ner.test`
+// prints every token with its gold and predicted tag, the conlleval input.
+func memm(args []string) {
+	if modelPath := argString(args, "model", ""); modelPath != "" {
+		model, err := IIS.LoadMEMM(modelPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		sentences, err := data.ReadConll(argString(args, "input", ""))
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, sentence := range sentences {
+			tags, _ := model.Tag(sentence.Tokens)
+			for i, token := range sentence.Tokens {
+				fmt.Printf("%s %s %s\n", strings.Join(token, " "), sentence.Tags[i], tags[i])
+			}
+			fmt.Println()
+		}
+		logging.With(model.Evaluate(sentences).Fields()...).Info("tagged", "sentences", len(sentences))
+		return
+	}
+
+	trainPath := argString(args, "train", "")
+	if trainPath == "" {
+		fmt.Println("usage: memm --train=<conll> [--test=<conll>] [--iter=100] [--cutoff=1] " +
+			"[--solver=iis|gis|lbfgs] [--variance=0] [--precision=float64] [--history=<path>] [--out=<path>] | memm --model=<path> --input=<conll>")
+		os.Exit(1)
+	}
+	training, err := data.ReadConll(trainPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	var testing []data.Sentence
+	if testPath := argString(args, "test", ""); testPath != "" {
+		if testing, err = data.ReadConll(testPath); err != nil {
+			panic(err.Error())
+		}
+	}
+	recorder, err := metrics.NewRecorder("memm", argString(args, "history", "./resource/memm_history.csv"), "")
+	if err != nil {
+		panic(err.Error())
+	}
+	defer recorder.Close()
+	model := &IIS.MEMM{Classifier: IIS.Classifier{Cutoff: argInt(args, "cutoff", 1),
+		Precision: argString(args, "precision", ""), Solver: argString(args, "solver", ""),
+		Variance: argFloat(args, "variance", 0), Recorder: recorder}}
+	if err := model.Train(training, testing, argInt(args, "iter", 100)); err != nil {
+		panic(err.Error())
+	}
+	if len(testing) > 0 {
+		logging.With(model.Evaluate(testing).Fields()...).Info("viterbi", "sentences", len(testing))
+	}
+	if outPath := argString(args, "out", ""); outPath != "" {
+		if err := model.Save(outPath); err != nil {
+			panic(err.Error())
+		}
+		logging.Info("model saved", "path", outPath)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"LR"
+	"config"
+	"io"
+	"logging"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// online keeps training the lr model from labeled lines on stdin or a
+// followed file, snapshots go to <modelPath>/online.model, e.g.
+// `tail -F impressions.log | online` or `online --input=impressions.log --follow --model=../resource/1553269965.model`
+func online(args []string) {
+	conf := config.GetLRConf()
+
+	model := &LR.LogisticRegression{}
+	if modelPath := argString(args, "model", conf.BPointPath); modelPath != "" {
+		loaded, err := LR.LoadModel(modelPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		model = loaded
+		logging.Info("continue from model", "path", modelPath)
+	}
+
+	var input io.Reader = os.Stdin
+	if path := argString(args, "input", "-"); path != "-" {
+		var err error
+		if argBool(args, "follow") {
+			input, err = LR.Follow(path)
+		} else {
+			input, err = os.Open(path)
+		}
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+
+	model.Recorder = LR.NewRecorder("online", conf)
+	defer model.Recorder.Close()
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	if err := LR.NewOnline(model, conf).Run(input, signalChan); err != nil {
+		panic(err.Error())
+	}
+}
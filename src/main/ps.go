@@ -0,0 +1,133 @@
+package main
+
+import (
+	"LR"
+	"config"
+	"fmt"
+	"logging"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"ps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parameterServer trains the lr model with one server process owning the
+// weights and several worker processes, e.g.
+// `ps --role=launch --workers=4 --iter=10 --addr=127.0.0.1:9100`
+// starts the server in this process and the workers as child processes.
+// `--role=server` and `--role=worker --shard=<i>` run a single side.
+// The server gives up when no worker makes progress for `--timeout=600`
+// seconds, 0 waits forever.
+func parameterServer(args []string) {
+	conf := config.GetLRConf()
+	addr := argString(args, "addr", "127.0.0.1:9100")
+	workers := argInt(args, "workers", 4)
+	iter := argInt(args, "iter", 10)
+	timeout := time.Duration(argInt(args, "timeout", 600)) * time.Second
+
+	switch argString(args, "role", "launch") {
+	case "server":
+		runServer(conf, addr, workers, timeout, nil)
+	case "worker":
+		runWorker(conf, addr, argInt(args, "shard", 0), workers, iter)
+	case "launch":
+		runServer(conf, addr, workers, timeout, func(server *ps.Server) {
+			launchWorkers(server, args, addr, workers)
+		})
+	default:
+		fmt.Println("usage: ps [--role=launch|server|worker] [--addr=127.0.0.1:9100|unix:///tmp/ps.sock] " +
+			"[--workers=4] [--shard=0] [--iter=10] [--timeout=600]")
+		os.Exit(1)
+	}
+}
+
+func runServer(conf config.TrainConf, addr string, workers int, timeout time.Duration,
+	started func(server *ps.Server)) {
+	server := ps.NewServer(conf, workers)
+	listener, err := ps.Listen(addr)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer listener.Close()
+	if strings.HasPrefix(addr, "unix://") {
+		defer os.Remove(addr[len("unix://"):])
+	}
+	go server.Serve(listener)
+	logging.Info("parameter server listen", "addr", addr, "workers", workers)
+
+	start := time.Now()
+	if started != nil {
+		go started(server)
+	}
+	if err := server.Wait(timeout); err != nil {
+		panic(err.Error())
+	}
+	logging.Info("all workers done", "cost", time.Now().Sub(start))
+
+	model := server.Model()
+	if testing, err := LR.LoadSparseData(conf.TestPath); err == nil {
+		logging.With(model.Evaluate(testing).Fields()...).Info("test")
+	} else {
+		logging.Warn("load test data", "path", conf.TestPath, "err", err)
+	}
+	path := fmt.Sprintf("%s/%d.model", conf.ModelPath, time.Now().Unix())
+	if err := model.Save(path); err != nil {
+		panic(err.Error())
+	}
+	logging.Info("model saved", "path", path)
+}
+
+func runWorker(conf config.TrainConf, addr string, shard, workers, iter int) {
+	items, err := LR.LoadSparseData(conf.TrainPath)
+	if err != nil {
+		panic(err.Error())
+	}
+	worker, err := ps.Dial(addr)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer worker.Close()
+	// every worker process records to a file of its own, and only the
+	// server could hold the metrics port
+	if conf.History != "" {
+		ext := filepath.Ext(conf.History)
+		conf.History = fmt.Sprintf("%s_worker%d%s", strings.TrimSuffix(conf.History, ext), shard, ext)
+	}
+	conf.MetricsAddr = ""
+	worker.Recorder = LR.NewRecorder(fmt.Sprintf("ps_worker%d", shard), conf)
+	defer worker.Recorder.Close()
+	if err := worker.Train(ps.Shard(items, shard, workers), iter, conf.OneBatch); err != nil {
+		panic(err.Error())
+	}
+}
+
+// launchWorkers starts this binary once per shard with --role=worker, a
+// worker that exits with an error fails the server.
+func launchWorkers(server *ps.Server, args []string, addr string, workers int) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		workerArgs := append([]string{"ps", "--role=worker",
+			"--addr=" + addr,
+			fmt.Sprintf("--shard=%d", i),
+			fmt.Sprintf("--workers=%d", workers),
+			fmt.Sprintf("--iter=%d", argInt(args, "iter", 10))}, childArgs(fmt.Sprintf("-worker%d", i))...)
+		cmd := exec.Command(os.Args[0], workerArgs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			panic(err.Error())
+		}
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			if err := cmd.Wait(); err != nil {
+				logging.Error("worker exit", "worker", shard, "err", err)
+				server.Fail(fmt.Errorf("worker %d exit: %v", shard, err))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
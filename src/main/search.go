@@ -0,0 +1,81 @@
+package main
+
+import (
+	"LR"
+	"config"
+	"fmt"
+	"logging"
+	"metrics"
+	"os"
+	"tuning"
+)
+
+// search tunes the lr or softmax section of the config, e.g.
+// `search --spec=./config/search_lr.yml`
+// every trial trains on the section's train path and is scored on its test path.
+func search(args []string) {
+	specPath := argString(args, "spec", "")
+	if specPath == "" {
+		fmt.Println("usage: search --spec=<path>")
+		os.Exit(1)
+	}
+	spec, err := tuning.LoadSpec(specPath)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	var base config.TrainConf
+	var objective tuning.Objective
+	switch spec.Model {
+	case "lr":
+		base = config.GetLRConf()
+		training, err := LR.LoadSparseData(base.TrainPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		testing, err := LR.LoadSparseData(base.TestPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		objective = func(conf config.TrainConf, iter int, previous interface{}) (metrics.Result, interface{}) {
+			model, ok := previous.(*LR.LogisticRegression)
+			if !ok {
+				model = &LR.LogisticRegression{}
+			}
+			model.Fit(conf, training, testing, iter)
+			return model.Evaluate(testing), model
+		}
+	case "softmax":
+		base = config.GetSoftmaxConf()
+		training, err := LR.LoadSoftmaxData(base, base.TrainPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		testing, err := LR.LoadSoftmaxData(base, base.TestPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		objective = func(conf config.TrainConf, iter int, previous interface{}) (metrics.Result, interface{}) {
+			model, ok := previous.(*LR.SoftMaxRegression)
+			if !ok {
+				model = &LR.SoftMaxRegression{}
+			}
+			model.Fit(conf, training, testing, iter)
+			return model.Evaluate(testing), model
+		}
+	default:
+		panic(fmt.Sprintf("search does not support model %q", spec.Model))
+	}
+
+	best, err := tuning.NewSearch(spec, base, objective).Run()
+	if err != nil {
+		panic(err.Error())
+	}
+	fmt.Printf("best trial %d %v: %s\n", best.ID, best.Params, best.Metrics.String())
+	if spec.Best != "" {
+		if err := tuning.SaveBest(spec.Best, spec.Model, best); err != nil {
+			panic(err.Error())
+		}
+		logging.Info("best config saved", "path", spec.Best)
+	}
+}
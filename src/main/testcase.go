@@ -2,32 +2,58 @@ package main
 
 import (
 	"LR"
+	"config"
 	"fmt"
+	"logging"
 	"math"
-	"maxent/IIS"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 )
 
-func maxent() {
-
-	model := IIS.MaxEntIIS{}
-	model.LoadData(
-		"./resource/Mnist/mnist_test.csv",
-		"./resource/Mnist/mnist_train.csv")
-
-	model.StartTraining(1500, 1)
-	//model.StartTraining(200)
-	//start := time.Now()
-	//model.TestAllPwXY()
-	//fmt.Println(time.Now().Sub(start))
-
-	//fmt.Println(math.Exp(0.9))
-}
-
 func main() {
+	if err := logging.Init(config.GetLogConf()); err != nil {
+		panic(err.Error())
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			inspect(os.Args[2:])
+			return
+		case "calibrate":
+			calibrate(os.Args[2:])
+			return
+		case "cv":
+			cv(os.Args[2:])
+			return
+		case "search":
+			search(os.Args[2:])
+			return
+		case "enet":
+			elasticNet(os.Args[2:])
+			return
+		case "online":
+			online(os.Args[2:])
+			return
+		case "ps":
+			parameterServer(os.Args[2:])
+			return
+		case "dp":
+			dataParallel(os.Args[2:])
+			return
+		case "classifier":
+			classifier(os.Args[2:])
+			return
+		case "maxent":
+			maxent(os.Args[2:])
+			return
+		case "memm":
+			memm(os.Args[2:])
+			return
+		}
+	}
+
 	a := 0.2
 	fmt.Println(a + math.NaN())
 	fmt.Println(time.Now().Unix())
@@ -0,0 +1,443 @@
+package IIS
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"logging"
+	"math"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+	"param"
+	"sort"
+	"time"
+)
+
+// Classifier is a maximum entropy classifier over arbitrary contextual
+// predicates. Every (predicate, label) pair seen at least Cutoff times in
+// training is a feature, weights are fitted by the solver named in Solver.
+type Classifier struct {
+	// features seen fewer times are dropped, 0 keeps all
+	Cutoff int
+	// float32 keeps the weights in single precision
+	Precision string
+	// Solver is iis, gis or lbfgs, empty is iis
+	Solver string
+	// Variance of the Gaussian prior on every weight, 0 trains without prior
+	Variance float64
+	// Recorder gets the metrics of every iteration when set
+	Recorder *metrics.Recorder
+
+	labels     []string
+	labelIndex map[string]int
+	predicates map[string]int
+	// predFeatures[p] lists the features of predicate p, feature f is
+	// weights[f] and belongs to label featureLabel[f]
+	predFeatures [][]int
+	featureLabel []int
+	weights      param.Vector
+	// bound is C >= f#(x, y) over the training events, correction the weight
+	// of the GIS feature C - f#(x, y)
+	bound      float64
+	correction float64
+
+	// training state of the Problem methods
+	training           []data.Event
+	observed           []float64
+	observedCorrection float64
+	sums               []float64
+	sumIndex           map[float64]int
+	layout             *histogramLayout
+}
+
+// classifierFile is the saved form, Features[p] lists the labels of the
+// features of Predicates[p] in weight order.
+type classifierFile struct {
+	Labels     []string
+	Predicates []string
+	Features   [][]int
+	Weights    param.Vector
+	Precision  string  `json:",omitempty"`
+	Bound      float64 `json:",omitempty"`
+	Correction float64 `json:",omitempty"`
+}
+
+func (c *Classifier) Labels() []string {
+	return c.labels
+}
+
+func (c *Classifier) FeatureCount() int {
+	return len(c.featureLabel)
+}
+
+// build indexes labels and predicates and keeps the features passing the
+// cutoff, returning the empirical count of every feature.
+func (c *Classifier) build(events []data.Event) []float64 {
+	c.labelIndex = make(map[string]int)
+	c.labels = nil
+	for _, event := range events {
+		if _, ok := c.labelIndex[event.Label]; !ok {
+			c.labelIndex[event.Label] = len(c.labels)
+			c.labels = append(c.labels, event.Label)
+		}
+	}
+	// labels in name order, so models trained on shuffled data match
+	sort.Strings(c.labels)
+	for i, label := range c.labels {
+		c.labelIndex[label] = i
+	}
+
+	type pair struct {
+		predicate string
+		label     int
+	}
+	counts := make(map[pair]int)
+	sums := make(map[pair]float64)
+	for _, event := range events {
+		label := c.labelIndex[event.Label]
+		for i, predicate := range event.Predicates {
+			key := pair{predicate, label}
+			counts[key]++
+			sums[key] += event.Value(i)
+		}
+	}
+	var kept []pair
+	for key, count := range counts {
+		if count >= c.Cutoff {
+			kept = append(kept, key)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].predicate == kept[j].predicate {
+			return kept[i].label < kept[j].label
+		}
+		return kept[i].predicate < kept[j].predicate
+	})
+
+	c.predicates = make(map[string]int)
+	c.predFeatures = nil
+	c.featureLabel = make([]int, len(kept))
+	observed := make([]float64, len(kept))
+	for f, key := range kept {
+		p, ok := c.predicates[key.predicate]
+		if !ok {
+			p = len(c.predFeatures)
+			c.predicates[key.predicate] = p
+			c.predFeatures = append(c.predFeatures, nil)
+		}
+		c.predFeatures[p] = append(c.predFeatures[p], f)
+		c.featureLabel[f] = key.label
+		observed[f] = sums[key]
+	}
+	c.weights = param.NewVector(len(kept), c.Precision)
+	return observed
+}
+
+// scores fills probs with p(label|event) and counts with f#(event, label),
+// unknown predicates are ignored.
+func (c *Classifier) scores(event *data.Event, probs, counts []float64) {
+	for li := range probs {
+		probs[li] = 0
+		counts[li] = 0
+	}
+	for i, predicate := range event.Predicates {
+		if p, ok := c.predicates[predicate]; ok {
+			value := event.Value(i)
+			for _, f := range c.predFeatures[p] {
+				probs[c.featureLabel[f]] += c.weights.At(f) * value
+				counts[c.featureLabel[f]] += value
+			}
+		}
+	}
+	if c.correction != 0 {
+		for li := range probs {
+			probs[li] += c.correction * (c.bound - counts[li])
+		}
+	}
+	max := math.Inf(-1)
+	for _, s := range probs {
+		max = math.Max(max, s)
+	}
+	Zw := 0.0
+	for li := range probs {
+		probs[li] = math.Exp(probs[li] - max)
+		Zw += probs[li]
+	}
+	for li := range probs {
+		probs[li] /= Zw
+	}
+}
+
+// Probabilities returns p(label|event) in the order of Labels().
+func (c *Classifier) Probabilities(event data.Event) []float64 {
+	probs := make([]float64, len(c.labels))
+	c.scores(&event, probs, make([]float64, len(c.labels)))
+	return probs
+}
+
+func (c *Classifier) Predict(event data.Event) string {
+	probs := c.Probabilities(event)
+	best := 0
+	for li, p := range probs {
+		if p > probs[best] {
+			best = li
+		}
+	}
+	return c.labels[best]
+}
+
+// Train runs iter solver iterations over training, testing is evaluated
+// after every iteration when given.
+func (c *Classifier) Train(training, testing []data.Event, iter int) error {
+	if len(training) == 0 {
+		return errors.New("no training events")
+	}
+	solver, err := NewSolver(c.Solver, c.Variance)
+	if err != nil {
+		return err
+	}
+	defer func() { c.training, c.observed, c.sums, c.sumIndex, c.layout = nil, nil, nil, nil, nil }()
+	if err := c.prepare(training); err != nil {
+		return err
+	}
+
+	weights := append([]float64(nil), c.weights.Float64s()...)
+	for it := 0; it < iter; it++ {
+		start := time.Now()
+		trainLoss, err := solver.Iterate(c, weights)
+		if err != nil {
+			logging.Warn("solver stopped", "iter", it, "err", err)
+			break
+		}
+
+		result := c.Evaluate(testing)
+		cost := time.Now().Sub(start)
+		logging.With(result.Fields()...).Info("iter done", "iter", it, "train_loss", trainLoss, "cost", cost)
+		c.Recorder.Record(metrics.Record{Epoch: it, Step: it + 1, TrainLoss: trainLoss,
+			Throughput: float64(len(training)) / cost.Seconds(), Seconds: cost.Seconds(), Validation: result})
+	}
+	return nil
+}
+
+// prepare builds the features of training and the state of the Problem
+// methods.
+func (c *Classifier) prepare(training []data.Event) error {
+	c.observed = c.build(training)
+	if len(c.observed) == 0 {
+		return fmt.Errorf("no feature passes cutoff %d", c.Cutoff)
+	}
+	c.training = training
+	c.sums, c.sumIndex, c.layout = nil, nil, nil
+
+	// C is the largest feature sum of any event under any label, the
+	// correction feature tops every f#(x, y) up to it
+	c.bound, c.correction, c.observedCorrection = 0, 0, 0
+	probs := make([]float64, len(c.labels))
+	counts := make([]float64, len(c.labels))
+	trueCounts := make([]float64, len(training))
+	for ei := range training {
+		c.scores(&training[ei], probs, counts)
+		for _, count := range counts {
+			c.bound = math.Max(c.bound, count)
+		}
+		trueCounts[ei] = counts[c.labelIndex[training[ei].Label]]
+	}
+	for _, count := range trueCounts {
+		c.observedCorrection += c.bound - count
+	}
+	logging.Info("classifier data", "events", len(training), "labels", len(c.labels),
+		"predicates", len(c.predFeatures), "features", len(c.observed), "C", c.bound, "solver", c.Solver)
+	return nil
+}
+
+// Evaluate reports accuracy and logloss, events with a label unknown to the
+// model count as wrong.
+func (c *Classifier) Evaluate(events []data.Event) metrics.Result {
+	return metrics.Parallel(len(events), 0, metrics.NewMulticlassAccumulator,
+		func(acc metrics.Accumulator, start, end int) {
+			multiclass := acc.(*metrics.MulticlassAccumulator)
+			probs := make([]float64, len(c.labels))
+			counts := make([]float64, len(c.labels))
+			for ei := start; ei < end; ei++ {
+				c.scores(&events[ei], probs, counts)
+				predicted := 0
+				for li, p := range probs {
+					if p > probs[predicted] {
+						predicted = li
+					}
+				}
+				label, ok := c.labelIndex[events[ei].Label]
+				trueProb := 0.0
+				if ok {
+					trueProb = probs[label]
+				} else {
+					label = -1
+				}
+				multiclass.Add(predicted, label, trueProb)
+			}
+		})
+}
+
+func (c *Classifier) Save(path string) error {
+	predicates := make([]string, len(c.predFeatures))
+	for predicate, p := range c.predicates {
+		predicates[p] = predicate
+	}
+	features := make([][]int, len(c.predFeatures))
+	for p, fs := range c.predFeatures {
+		for _, f := range fs {
+			features[p] = append(features[p], c.featureLabel[f])
+		}
+	}
+	content, err := json.Marshal(classifierFile{Labels: c.labels, Predicates: predicates,
+		Features: features, Weights: c.weights, Precision: c.Precision,
+		Bound: c.bound, Correction: c.correction})
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func LoadClassifier(path string) (*Classifier, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := classifierFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Features) != len(file.Predicates) {
+		return nil, fmt.Errorf("%s: %d predicates but %d feature lists", path, len(file.Predicates), len(file.Features))
+	}
+	c := &Classifier{Precision: file.Precision, labels: file.Labels,
+		bound: file.Bound, correction: file.Correction,
+		labelIndex: make(map[string]int), predicates: make(map[string]int),
+		predFeatures: make([][]int, len(file.Predicates))}
+	for li, label := range file.Labels {
+		c.labelIndex[label] = li
+	}
+	for p, predicate := range file.Predicates {
+		c.predicates[predicate] = p
+		for _, label := range file.Features[p] {
+			if label < 0 || label >= len(c.labels) {
+				return nil, fmt.Errorf("%s: predicate %q has unknown label %d", path, predicate, label)
+			}
+			c.predFeatures[p] = append(c.predFeatures[p], len(c.featureLabel))
+			c.featureLabel = append(c.featureLabel, label)
+		}
+	}
+	if file.Weights.Len() != len(c.featureLabel) {
+		return nil, fmt.Errorf("%s: %d features but %d weights", path, len(c.featureLabel), file.Weights.Len())
+	}
+	c.weights = file.Weights
+	c.weights.Convert(c.Precision)
+	return c, nil
+}
+
+// SampleCount, Bound, Observed, SetWeights and Expect make the classifier a
+// Problem while Train runs, FeatureCount is shared with the public API.
+
+func (c *Classifier) SampleCount() int {
+	return len(c.training)
+}
+
+func (c *Classifier) Bound() float64 {
+	return c.bound
+}
+
+func (c *Classifier) Observed() ([]float64, float64) {
+	return c.observed, c.observedCorrection
+}
+
+func (c *Classifier) SetWeights(weights []float64, correction float64) {
+	for f, w := range weights {
+		c.weights.Set(f, w)
+	}
+	c.correction = correction
+}
+
+func (c *Classifier) Expect(expected []float64) (float64, float64) {
+	return c.ExpectHistogram(expected, nil)
+}
+
+// FeatureSums lays out the f#(x, y) histogram, real valued predicates make
+// f# vary even without a cutoff.
+func (c *Classifier) FeatureSums() ([]int, []float64) {
+	if c.layout == nil {
+		c.sums = nil
+		c.sumIndex = make(map[float64]int)
+		probs := make([]float64, len(c.labels))
+		counts := make([]float64, len(c.labels))
+		for ei := range c.training {
+			c.scores(&c.training[ei], probs, counts)
+			for _, count := range counts {
+				if _, ok := c.sumIndex[count]; !ok {
+					c.sumIndex[count] = len(c.sums)
+					c.sums = append(c.sums, count)
+				}
+			}
+		}
+		pairs := newPairSet(len(c.featureLabel), len(c.sums))
+		for ei := range c.training {
+			event := &c.training[ei]
+			c.scores(event, probs, counts)
+			for _, predicate := range event.Predicates {
+				if p, ok := c.predicates[predicate]; ok {
+					for _, f := range c.predFeatures[p] {
+						pairs.add(f, c.sumIndex[counts[c.featureLabel[f]]])
+					}
+				}
+			}
+		}
+		c.layout = pairs.layout(len(c.featureLabel), c.sums)
+	}
+	return c.layout.offsets, c.layout.sums
+}
+
+// ExpectHistogram is Expect when histogram is nil.
+func (c *Classifier) ExpectHistogram(expected, histogram []float64) (float64, float64) {
+	for f := range expected {
+		expected[f] = 0
+	}
+	if histogram != nil {
+		c.FeatureSums()
+		for k := range histogram {
+			histogram[k] = 0
+		}
+	}
+	correction, nll := 0.0, 0.0
+	probs := make([]float64, len(c.labels))
+	counts := make([]float64, len(c.labels))
+	ranks := make([]int32, len(c.labels))
+	for ei := range c.training {
+		event := &c.training[ei]
+		c.scores(event, probs, counts)
+		nll += metrics.NegLog(probs[c.labelIndex[event.Label]])
+		for li, p := range probs {
+			correction += p * (c.bound - counts[li])
+			if histogram != nil {
+				ranks[li] = int32(c.sumIndex[counts[li]])
+			}
+		}
+		for i, predicate := range event.Predicates {
+			if p, ok := c.predicates[predicate]; ok {
+				value := event.Value(i)
+				for _, f := range c.predFeatures[p] {
+					label := c.featureLabel[f]
+					expected[f] += probs[label] * value
+					if histogram != nil {
+						histogram[c.layout.entry(f, ranks[label])] += probs[label] * value
+					}
+				}
+			}
+		}
+	}
+	return correction, nll
+}
@@ -0,0 +1,91 @@
+package IIS
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"maxent/dataformat"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sentiment draws events whose words lean towards their label, `rare`
+// occurs once only.
+func sentiment(n int, seed int64) []data.Event {
+	r := rand.New(rand.NewSource(seed))
+	positive := []string{"good", "great", "fun"}
+	negative := []string{"bad", "boring", "awful"}
+	neutral := []string{"movie", "plot", "actor"}
+	events := make([]data.Event, n)
+	for i := range events {
+		label, words := "pos", positive
+		if r.Intn(2) == 0 {
+			label, words = "neg", negative
+		}
+		event := data.Event{Label: label}
+		for k := 0; k < 4; k++ {
+			if r.Float64() < 0.7 {
+				event.Predicates = append(event.Predicates, "word="+words[r.Intn(3)])
+			} else {
+				event.Predicates = append(event.Predicates, "word="+neutral[r.Intn(3)])
+			}
+		}
+		events[i] = event
+	}
+	events[0].Predicates = append(events[0].Predicates, "rare")
+	return events
+}
+
+func TestClassifierLearnsPredicates(t *testing.T) {
+	training, testing := sentiment(400, 1), sentiment(200, 2)
+	c := &Classifier{Cutoff: 2}
+	if err := c.Train(training, testing, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.predicates["rare"]; ok {
+		t.Error("feature below cutoff kept")
+	}
+	result := c.Evaluate(testing)
+	if result["accuracy"] < 0.85 {
+		t.Errorf("accuracy %v", result["accuracy"])
+	}
+	if got := c.Predict(data.Event{Predicates: []string{"word=great", "word=plot", "unseen"}}); got != "pos" {
+		t.Errorf("predicted %s", got)
+	}
+
+	dir, err := ioutil.TempDir("", "classifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadClassifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range testing[:20] {
+		want, got := c.Probabilities(event), loaded.Probabilities(event)
+		for li := range want {
+			if math.Abs(want[li]-got[li]) > 1e-12 {
+				t.Fatalf("loaded model gives %v, trained %v", got, want)
+			}
+		}
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	event, ok := data.ParseEvent("B-PER word=John prefix=Jo 12:0.5")
+	if !ok || event.Label != "B-PER" || len(event.Predicates) != 3 {
+		t.Fatalf("parsed %+v", event)
+	}
+	if event.Predicates[2] != "12" || event.Value(2) != 0.5 || event.Value(0) != 1 {
+		t.Errorf("values %+v", event)
+	}
+	if _, ok := data.ParseEvent("  "); ok {
+		t.Error("blank line parsed")
+	}
+}
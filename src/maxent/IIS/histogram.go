@@ -0,0 +1,92 @@
+package IIS
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// pairs above this are collected in a map instead of a bitset, 32MB
+const maxPairBits = 1 << 28
+
+// pairSet collects the (feature, f#) pairs that occur in the training data,
+// rank is the position of f# in the distinct values.
+type pairSet struct {
+	ranks int
+	bits  []uint64
+	set   map[int]bool
+}
+
+func newPairSet(features, ranks int) *pairSet {
+	p := &pairSet{ranks: ranks}
+	if n := features * ranks; n <= maxPairBits {
+		p.bits = make([]uint64, (n+63)/64)
+	} else {
+		p.set = make(map[int]bool)
+	}
+	return p
+}
+
+func (p *pairSet) add(f, rank int) {
+	k := f*p.ranks + rank
+	if p.bits != nil {
+		p.bits[k/64] |= 1 << uint(k%64)
+	} else {
+		p.set[k] = true
+	}
+}
+
+// histogramLayout is the sparse f# histogram of the Histogram interface,
+// feature f owns entries offsets[f]:offsets[f+1] and ranks[k] is the
+// position of sums[k] in the distinct values, ascending for every feature.
+type histogramLayout struct {
+	offsets []int
+	ranks   []int32
+	sums    []float64
+}
+
+// layout gives every added pair an entry, distinct[rank] is the f# value.
+func (p *pairSet) layout(features int, distinct []float64) *histogramLayout {
+	var keys []int
+	if p.bits != nil {
+		for w, word := range p.bits {
+			for ; word != 0; word &= word - 1 {
+				keys = append(keys, w*64+bits.TrailingZeros64(word))
+			}
+		}
+	} else {
+		for k := range p.set {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+	}
+	l := &histogramLayout{
+		offsets: make([]int, features+1),
+		ranks:   make([]int32, len(keys)),
+		sums:    make([]float64, len(keys)),
+	}
+	for k, key := range keys {
+		f, rank := key/p.ranks, key%p.ranks
+		l.offsets[f+1]++
+		l.ranks[k] = int32(rank)
+		l.sums[k] = distinct[rank]
+	}
+	for f := 0; f < features; f++ {
+		l.offsets[f+1] += l.offsets[f]
+	}
+	return l
+}
+
+// entry is the histogram position of feature f at rank, the pair must have
+// been added.
+func (l *histogramLayout) entry(f int, rank int32) int {
+	lo, hi := l.offsets[f], l.offsets[f+1]
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if l.ranks[mid] < rank {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
@@ -0,0 +1,38 @@
+package IIS
+
+// span is the run featureArray[start:end] of features sharing one
+// (XDIndex, XDValue) and differing in label only.
+type span struct {
+	start, end int32
+}
+
+// buildIndex fills featureIndex and featureLabel from the sorted
+// featureArray, featureIndex[j][v] is the span of pixel j with value v.
+func (m *MaxEntIIS) buildIndex() {
+	m.featureIndex = nil
+	m.featureLabel = make([]int, len(m.featureArray))
+	for fi, feature := range m.featureArray {
+		m.featureLabel[fi] = feature.LabelIndex
+		for len(m.featureIndex) <= feature.XDIndex {
+			m.featureIndex = append(m.featureIndex, nil)
+		}
+		values := m.featureIndex[feature.XDIndex]
+		for len(values) <= int(feature.XDValue) {
+			values = append(values, span{})
+		}
+		if values[feature.XDValue].end == 0 {
+			values[feature.XDValue].start = int32(fi)
+		}
+		values[feature.XDValue].end = int32(fi) + 1
+		m.featureIndex[feature.XDIndex] = values
+	}
+}
+
+// active returns the span of features matching pixel j with value v, empty
+// when none was seen in training.
+func (m *MaxEntIIS) active(j int, v uint8) span {
+	if j < len(m.featureIndex) && int(v) < len(m.featureIndex[j]) {
+		return m.featureIndex[j][v]
+	}
+	return span{}
+}
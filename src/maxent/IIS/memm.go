@@ -0,0 +1,188 @@
+package IIS
+
+import (
+	"errors"
+	"math"
+	"maxent/dataformat"
+	"metrics"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// StartTag is the previous tag of the first token
+	StartTag string = "<s>"
+	endWord  string = "</s>"
+)
+
+// MEMM is a maximum entropy Markov model, p(tag | previous tag, sentence)
+// at every token is a Classifier over the predicates of tokenPredicates and
+// the previous tag, Tag finds the best tag sequence by Viterbi. The embedded
+// Classifier settings apply to training and the saved model is a
+// Classifier model.
+type MEMM struct {
+	Classifier
+}
+
+func LoadMEMM(path string) (*MEMM, error) {
+	c, err := LoadClassifier(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MEMM{Classifier: *c}, nil
+}
+
+// tokenPredicates lists the predicates of token i that do not depend on the
+// tags: the word and its neighbours, affixes, shape and the other columns.
+func tokenPredicates(tokens [][]string, i int) []string {
+	word := func(k int) string {
+		if k < 0 {
+			return StartTag
+		} else if k >= len(tokens) {
+			return endWord
+		}
+		return strings.ToLower(tokens[k][0])
+	}
+	w := []rune(tokens[i][0])
+	lower := word(i)
+	predicates := []string{"bias", "w=" + lower, "w-1=" + word(i-1), "w+1=" + word(i+1)}
+	if len(w) > 3 {
+		predicates = append(predicates, "pre3="+string(w[:3]), "suf3="+strings.ToLower(string(w[len(w)-3:])))
+	}
+	if unicode.IsUpper(w[0]) {
+		predicates = append(predicates, "cap")
+	}
+	if strings.IndexFunc(lower, unicode.IsDigit) >= 0 {
+		predicates = append(predicates, "digit")
+	}
+	if strings.Contains(lower, "-") {
+		predicates = append(predicates, "hyphen")
+	}
+	for k, column := range tokens[i][1:] {
+		predicates = append(predicates, "c"+strconv.Itoa(k+1)+"="+column)
+	}
+	return predicates
+}
+
+// event is the classifier input of a token after the tag prev.
+func event(predicates []string, prev, tag string) data.Event {
+	return data.Event{Label: tag, Predicates: append(predicates[:len(predicates):len(predicates)], "prev="+prev)}
+}
+
+// Events turns sentences into one event per token, the previous tag is the
+// gold one.
+func Events(sentences []data.Sentence) []data.Event {
+	var events []data.Event
+	for _, sentence := range sentences {
+		prev := StartTag
+		for i, tag := range sentence.Tags {
+			events = append(events, event(tokenPredicates(sentence.Tokens, i), prev, tag))
+			prev = tag
+		}
+	}
+	return events
+}
+
+// Train fits the classifier on the tokens of training, testing is evaluated
+// token by token with gold previous tags after every iteration.
+func (m *MEMM) Train(training, testing []data.Sentence, iter int) error {
+	if len(training) == 0 {
+		return errors.New("no training sentences")
+	}
+	return m.Classifier.Train(Events(training), Events(testing), iter)
+}
+
+// logProbabilities fills scores[prev][tag] with log p(tag | prev, token i),
+// prev StartTag for the first token and a tag index after it.
+func (m *MEMM) logProbabilities(tokens [][]string, i int, scores [][]float64) {
+	predicates := tokenPredicates(tokens, i)
+	probs := make([]float64, len(m.labels))
+	counts := make([]float64, len(m.labels))
+	for prev := range scores {
+		prevTag := StartTag
+		if i > 0 {
+			prevTag = m.labels[prev]
+		}
+		e := event(predicates, prevTag, "")
+		m.scores(&e, probs, counts)
+		for li, p := range probs {
+			scores[prev][li] = math.Log(p)
+		}
+	}
+}
+
+// Tag returns the most probable tag sequence of the tokens and its log
+// probability, delta[i][t] is the best log probability of tokens up to i
+// ending in tag t and back[i][t] the tag before it.
+func (m *MEMM) Tag(tokens [][]string) ([]string, float64) {
+	n, tags := len(tokens), len(m.labels)
+	if n == 0 {
+		return nil, 0
+	}
+	delta, back := make([][]float64, n), make([][]int, n)
+	scores := make([][]float64, tags)
+	for prev := range scores {
+		scores[prev] = make([]float64, tags)
+	}
+	for i := range tokens {
+		delta[i], back[i] = make([]float64, tags), make([]int, tags)
+		if i == 0 {
+			m.logProbabilities(tokens, 0, scores[:1])
+			copy(delta[0], scores[0])
+			continue
+		}
+		m.logProbabilities(tokens, i, scores)
+		for t := range delta[i] {
+			delta[i][t] = math.Inf(-1)
+			for prev := range scores {
+				if s := delta[i-1][prev] + scores[prev][t]; s > delta[i][t] {
+					delta[i][t], back[i][t] = s, prev
+				}
+			}
+		}
+	}
+	best := 0
+	for t := range delta[n-1] {
+		if delta[n-1][t] > delta[n-1][best] {
+			best = t
+		}
+	}
+	logProb := delta[n-1][best]
+	result := make([]string, n)
+	for i := n - 1; i >= 0; i-- {
+		result[i] = m.labels[best]
+		best = back[i][best]
+	}
+	return result, logProb
+}
+
+// Evaluate tags every sentence by Viterbi and reports token accuracy, the
+// logloss is of the gold tag after the gold previous tag. Tags unknown to
+// the model count as wrong.
+func (m *MEMM) Evaluate(sentences []data.Sentence) metrics.Result {
+	return metrics.Parallel(len(sentences), 0, metrics.NewMulticlassAccumulator,
+		func(acc metrics.Accumulator, start, end int) {
+			multiclass := acc.(*metrics.MulticlassAccumulator)
+			probs := make([]float64, len(m.labels))
+			counts := make([]float64, len(m.labels))
+			for si := start; si < end; si++ {
+				sentence := sentences[si]
+				tags, _ := m.Tag(sentence.Tokens)
+				prev := StartTag
+				for i, tag := range sentence.Tags {
+					e := event(tokenPredicates(sentence.Tokens, i), prev, tag)
+					m.scores(&e, probs, counts)
+					label, ok := m.labelIndex[tag]
+					trueProb := 0.0
+					if ok {
+						trueProb = probs[label]
+					} else {
+						label = -1
+					}
+					multiclass.Add(m.labelIndex[tags[i]], label, trueProb)
+					prev = tag
+				}
+			}
+		})
+}
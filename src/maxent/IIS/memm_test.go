@@ -0,0 +1,98 @@
+package IIS
+
+import (
+	"math"
+	"math/rand"
+	"maxent/dataformat"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tagged draws `det noun verb det noun` sentences, `walk` is a noun after a
+// determiner and a verb after a noun, so only the previous tag tells.
+func tagged(n int, seed int64) []data.Sentence {
+	r := rand.New(rand.NewSource(seed))
+	words := map[string][]string{
+		"D": {"the", "a"},
+		"N": {"dog", "Cat", "walk"},
+		"V": {"sees", "walk", "likes"},
+	}
+	sentences := make([]data.Sentence, n)
+	for i := range sentences {
+		for _, tag := range strings.Fields("D N V D N") {
+			word := words[tag][r.Intn(len(words[tag]))]
+			sentences[i].Tokens = append(sentences[i].Tokens, []string{word})
+			sentences[i].Tags = append(sentences[i].Tags, tag)
+		}
+	}
+	return sentences
+}
+
+func trainMEMM(t *testing.T, iter int) *MEMM {
+	m := &MEMM{Classifier: Classifier{Solver: SolverLBFGS, Variance: 1}}
+	if err := m.Train(tagged(200, 1), nil, iter); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMEMMTagsByPreviousTag(t *testing.T) {
+	m := trainMEMM(t, 50)
+	test := tagged(50, 2)
+	if accuracy := m.Evaluate(test)["accuracy"]; accuracy < 0.99 {
+		t.Errorf("token accuracy %v", accuracy)
+	}
+	walk := [][]string{{"a"}, {"walk"}, {"walk"}, {"the"}, {"walk"}}
+	if tags, _ := m.Tag(walk); !reflect.DeepEqual(tags, strings.Fields("D N V D N")) {
+		t.Errorf("tags %v", tags)
+	}
+}
+
+func TestViterbiMatchesBruteForce(t *testing.T) {
+	m := trainMEMM(t, 3)
+	tokens := [][]string{{"walk"}, {"the"}, {"walk"}, {"walk"}}
+	tags, logProb := m.Tag(tokens)
+
+	n, labels := len(tokens), len(m.labels)
+	best, bestTags := math.Inf(-1), []string(nil)
+	sequence := make([]int, n)
+	for code := 0; code < int(math.Pow(float64(labels), float64(n))); code++ {
+		for i, c := 0, code; i < n; i, c = i+1, c/labels {
+			sequence[i] = c % labels
+		}
+		sum, prev := 0.0, StartTag
+		names := make([]string, n)
+		for i, li := range sequence {
+			names[i] = m.labels[li]
+			sum += math.Log(m.Probabilities(event(tokenPredicates(tokens, i), prev, ""))[li])
+			prev = names[i]
+		}
+		if sum > best {
+			best, bestTags = sum, names
+		}
+	}
+	if !reflect.DeepEqual(tags, bestTags) || math.Abs(logProb-best) > 1e-9 {
+		t.Errorf("viterbi %v %v, brute force %v %v", tags, logProb, bestTags, best)
+	}
+}
+
+func TestMEMMSaveLoad(t *testing.T) {
+	m := trainMEMM(t, 20)
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMEMM(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sentence := range tagged(20, 3) {
+		got, gotProb := loaded.Tag(sentence.Tokens)
+		want, wantProb := m.Tag(sentence.Tokens)
+		if !reflect.DeepEqual(got, want) || math.Abs(gotProb-wantProb) > 1e-9 {
+			t.Fatalf("loaded model tags %v %v, trained %v %v", got, gotProb, want, wantProb)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package IIS
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"logging"
 	"math"
 	"math/rand"
 	"maxent/dataformat"
-	"os"
+	"metrics"
+	"param"
+	"runtime"
 	"sort"
 	"sync"
 	"time"
@@ -28,6 +30,25 @@ func (fl FeatureList) Less(i, j int) bool {
 func (fl FeatureList) Swap(i, j int) { fl[i], fl[j] = fl[j], fl[i] }
 
 type MaxEntIIS struct {
+	// float32 keeps the feature weights in single precision, set it before
+	// SetData or LoadModel
+	Precision string
+	// Solver is iis, gis or lbfgs, empty is iis
+	Solver string
+	// Variance of the Gaussian prior on every weight, 0 trains without prior
+	Variance float64
+	// features seen fewer times in training are dropped, set it before
+	// LoadData or SetData
+	Cutoff int
+	// training stops once the mean log likelihood changes less between two
+	// iterations, 0 runs every iteration
+	Tolerance float64
+	// Recorder gets the metrics of every iteration when set
+	Recorder *metrics.Recorder
+	// Discretizer made the samples from raw pixels and is saved with the
+	// model, SetData leaves a set one alone and binarizes otherwise
+	Discretizer data.Discretizer
+
 	test  []*data.MnistSample
 	train []*data.MnistSample
 
@@ -35,6 +56,34 @@ type MaxEntIIS struct {
 	featureArray   FeatureList
 	featureFuncLen int
 	allPwXy        []float64
+	// featureIndex[j][v] spans the features of pixel j with value v in the
+	// sorted featureArray, featureLabel[fi] is featureArray[fi].LabelIndex
+	featureIndex [][]span
+	featureLabel []int
+	// model expectation of every feature, summed over the training samples
+	featureExp []float64
+	// weights[fi] belongs to featureArray[fi], FuncFeature.Weight is only
+	// filled when the model is saved
+	weights param.Vector
+	// mean negative log likelihood of the training samples, from the last
+	// calcAllPwXYV2
+	trainLoss float64
+	// empirical sum of every feature and of the GIS correction feature
+	observed           []float64
+	observedCorrection float64
+	// weight of the correction feature xDimension - f#(x, y), only GIS sets it
+	correction float64
+	// workers of the E-step, set by StartTraining
+	coreNum int
+	// distinct values of f#(x, y) in the training data, their positions and
+	// the histogram entries of the (feature, f#) pairs, filled by FeatureSums
+	sums     []float64
+	sumIndex map[float64]int
+	layout   *histogramLayout
+	// histograms of the E-step workers, kept between iterations
+	workerHistograms [][]float64
+	// iterations run so far, saved
+	iterations int
 
 	labelYCount int
 	M           float64
@@ -42,13 +91,40 @@ type MaxEntIIS struct {
 	probX       float64
 }
 
-func (m *MaxEntIIS) LoadData(trainPath, testPath string) {
-	var yCount int
-	m.test, m.labelYCount = data.ReadMnistCsv(trainPath)
-	m.train, yCount = data.ReadMnistCsv(testPath)
-	if yCount != m.labelYCount {
-		panic("output label not equal between training and test set")
+// LoadData reads the training and test csv files and fits the discretizer
+// on the training file, a missing or empty file is an error.
+func (m *MaxEntIIS) LoadData(trainPath, testPath string) error {
+	trainRows, labelYCount, err := data.ReadMnistPixels(trainPath)
+	if err != nil {
+		return fmt.Errorf("training set: %v", err)
+	}
+	testRows, yCount, err := data.ReadMnistPixels(testPath)
+	if err != nil {
+		return fmt.Errorf("test set: %v", err)
+	}
+	if yCount != labelYCount {
+		return errors.New("output label not equal between training and test set")
+	}
+	if m.Discretizer.Strategy == "" {
+		m.Discretizer = data.Binary()
 	}
+	m.Discretizer.Fit(trainRows)
+	return m.SetData(m.Discretizer.Samples(trainRows), m.Discretizer.Samples(testRows), labelYCount)
+}
+
+// SetData builds the feature functions from in memory samples, LoadData
+// uses it after reading the csv files.
+func (m *MaxEntIIS) SetData(train, test []*data.MnistSample, labelYCount int) error {
+	if len(train) == 0 {
+		return errors.New("no training samples")
+	}
+	m.train = train
+	m.test = test
+	m.labelYCount = labelYCount
+	if m.Discretizer.Strategy == "" {
+		m.Discretizer = data.Binary()
+	}
+	m.sums, m.sumIndex, m.layout, m.workerHistograms = nil, nil, nil, nil
 
 	m.xDimension = m.train[0].GetDataVectorLen()
 	featureMap := make(map[string]*data.FuncFeature)
@@ -75,172 +151,209 @@ func (m *MaxEntIIS) LoadData(trainPath, testPath string) {
 		}
 	}
 
+	// rare (label, pixel, value) combinations get huge weights, drop them
+	seen := len(featureMap)
 	totalCount := 0
-	for _, item := range featureMap {
-		totalCount += item.Count
+	for key, item := range featureMap {
+		if item.Count < m.Cutoff {
+			delete(featureMap, key)
+		} else {
+			totalCount += item.Count
+		}
 	}
 	m.featureFuncLen = len(featureMap)
 	m.featureArray = make(FeatureList, m.featureFuncLen)
+	m.featureExp = make([]float64, m.featureFuncLen)
+	m.weights = param.NewVector(m.featureFuncLen, m.Precision)
+	m.observed = make([]float64, m.featureFuncLen)
+	// every sample has one feature per pixel under its own label, so the
+	// observed correction feature is zero unless features get dropped
+	m.observedCorrection = float64(m.N*m.xDimension - totalCount)
 
 	arrayIndex := 0
 	rand.Seed(time.Now().Unix())
 	for _, item := range featureMap {
 		item.Prob = float64(item.Count) / float64(totalCount)
-		item.Weight = rand.Float64() / 74
 		m.featureArray[arrayIndex] = item
 		arrayIndex++
 	}
 
 	sort.Sort(m.featureArray)
+	m.buildIndex()
+	for fi, feature := range m.featureArray {
+		m.weights.Set(fi, rand.Float64()/74)
+		m.observed[fi] = float64(feature.Count)
+	}
 
-	fmt.Println("load data done")
-
-	return
+	logging.Info("load data done", "features", m.featureFuncLen, "dropped", seen-m.featureFuncLen,
+		"cutoff", m.Cutoff, "samples", m.N)
+	return nil
 }
 
-func (m *MaxEntIIS) ComputePwXY(sample *data.MnistSample) float64 {
-	Zw := 0.0
-	tmpSum := make([]float64, m.labelYCount)
-	fiY := sample.GetLabel()
-	for _, feature := range m.featureArray {
-		if feature.XDValue == sample.GetDataByIndex(feature.XDIndex) {
-			tmpSum[feature.LabelIndex] += feature.Weight
+// scores fills tmpSum with the weight sum of every label for dataVec and
+// counts with f#(x, label), the number of matching features.
+func (m *MaxEntIIS) scores(dataVec []uint8, tmpSum, counts []float64) {
+	for li := range tmpSum {
+		tmpSum[li] = 0
+		counts[li] = 0
+	}
+	for j, v := range dataVec {
+		s := m.active(j, v)
+		for fi := int(s.start); fi < int(s.end); fi++ {
+			tmpSum[m.featureLabel[fi]] += m.weights.At(fi)
+			counts[m.featureLabel[fi]]++
 		}
 	}
-
-	for i := 0; i < m.labelYCount; i++ {
-		tmpSum[i] = math.Exp(tmpSum[i])
-		Zw += tmpSum[i]
+	if m.correction != 0 {
+		for li := range tmpSum {
+			tmpSum[li] += m.correction * (m.Bound() - counts[li])
+		}
 	}
-	return tmpSum[fiY] / Zw
-
 }
 
-func (m *MaxEntIIS) ComputePwXYV2(dataVec []uint8, label int) float64 {
-	Zw := 0.0
-	maxWeight := 0.0
-	tmpSum := make([]float64, m.labelYCount)
-	for _, feature := range m.featureArray {
-		if feature.XDValue == dataVec[feature.XDIndex] {
-			tmpSum[feature.LabelIndex] += feature.Weight
-			if tmpSum[feature.LabelIndex] > maxWeight {
-				maxWeight = tmpSum[feature.LabelIndex]
-			}
-		}
+// probabilities turns the scores of every label into p(y|x) in place.
+func probabilities(tmpSum []float64) {
+	maxWeight := math.Inf(-1)
+	for _, s := range tmpSum {
+		maxWeight = math.Max(maxWeight, s)
 	}
-
-	for i := 0; i < m.labelYCount; i++ {
+	Zw := 0.0
+	for i := range tmpSum {
 		// -maxWeight 防止溢出
 		tmpSum[i] = math.Exp(tmpSum[i] - maxWeight)
 		Zw += tmpSum[i]
 	}
-	return tmpSum[label] / Zw
+	for i := range tmpSum {
+		tmpSum[i] /= Zw
+	}
+}
+
+func (m *MaxEntIIS) ComputePwXY(sample *data.MnistSample) float64 {
+	return m.ComputePwXYV2(sample.GetDataVec(), sample.GetLabel())
 }
 
+func (m *MaxEntIIS) ComputePwXYV2(dataVec []uint8, label int) float64 {
+	return m.Probabilities(dataVec)[label]
+}
+
+// StartTraining runs iter iterations of the solver named in m.Solver, the
+// test set is evaluated after every one. The E-step runs on coreNum workers,
+// 0 or less uses every cpu.
 func (m *MaxEntIIS) StartTraining(iter int, coreNum int) {
+	solver, err := NewSolver(m.Solver, m.Variance)
+	if err != nil {
+		panic(err.Error())
+	}
+	if coreNum <= 0 {
+		coreNum = runtime.NumCPU()
+	}
+	m.coreNum = coreNum
+	weights := append([]float64(nil), m.weights.Float64s()...)
+	lastLH := math.Inf(-1)
 	for i := 0; i < iter; i++ {
 		start := time.Now()
-		fmt.Println(" ------ ", start, " ------ iter", i)
-		m.calcAllPwXYV2()
-
-		deltaList := make([]float64, m.featureFuncLen)
-		for fi, feature := range m.featureArray {
-			/**
-			将calcAllPwXYV2中计算的 m.allPwXy[fi] * 1 / m.probX提出放到这里
-			*/
-			//deltaList[fi] = math.Log((feature.Prob)/m.allPwXy[fi]) * m.M
-			deltaList[fi] = math.Log((feature.Prob*m.probX)/m.allPwXy[fi]) * m.M
-		}
-		for fi := 0; fi < m.featureFuncLen; fi++ {
-			m.featureArray[fi].Weight += deltaList[fi]
+		trainLoss, err := solver.Iterate(m, weights)
+		if err != nil {
+			logging.Warn("solver stopped", "iter", i, "err", err)
+			break
 		}
-		m.Test()
-		if i%10 == 0 {
-
+		m.iterations++
+		result := m.Evaluate(m.test)
+		cost := time.Now().Sub(start)
+		lh := m.ComputeLH()
+		logging.With(result.Fields()...).Info("iter done", "iter", i, "train_loss", trainLoss,
+			"likelihood", lh, "cost", cost)
+		m.Recorder.Record(metrics.Record{Epoch: i, Step: i + 1, TrainLoss: trainLoss,
+			Throughput: float64(m.N) / cost.Seconds(), Seconds: cost.Seconds(), Validation: result})
+		if m.Tolerance > 0 && math.Abs(lh-lastLH) < m.Tolerance {
+			logging.Info("converged", "iter", i, "likelihood", lh, "change", lh-lastLH)
+			break
 		}
+		lastLH = lh
 	}
 }
 
 func (m *MaxEntIIS) Predict(item *data.MnistSample) bool {
-	tmpSum := make([]float64, m.labelYCount)
-	for _, feature := range m.featureArray {
-		if feature.XDValue == item.GetDataByIndex(feature.XDIndex) {
-			tmpSum[feature.LabelIndex] += feature.Weight
-		}
-	}
-
-	//if tmpSum[1] >= tmpSum[0] {
-	//	return 1 == item.GetLabel()
-	//} else {
-	//	return 0 == item.GetLabel()
-	//}
+	return item.GetLabel() == m.Classify(item.GetDataVec())
+}
 
-	max := -1000000000000.0
+// Classify returns the most probable label of binarized pixels.
+func (m *MaxEntIIS) Classify(dataVec []uint8) int {
+	tmpSum := make([]float64, m.labelYCount)
+	m.scores(dataVec, tmpSum, make([]float64, m.labelYCount))
 	maxLabel := 0
-	for li := 0; li < m.labelYCount; li++ {
-		if tmpSum[li] >= max {
-			max = tmpSum[li]
+	for li := range tmpSum {
+		if tmpSum[li] > tmpSum[maxLabel] {
 			maxLabel = li
 		}
 	}
-	return item.GetLabel() == maxLabel
+	return maxLabel
+}
+
+// Evaluate reports accuracy and logloss over samples, the feature scan of
+// every sample runs on every cpu.
+func (m *MaxEntIIS) Evaluate(samples []*data.MnistSample) metrics.Result {
+	return metrics.Parallel(len(samples), 0, metrics.NewMulticlassAccumulator,
+		func(acc metrics.Accumulator, start, end int) {
+			multiclass := acc.(*metrics.MulticlassAccumulator)
+			tmpSum := make([]float64, m.labelYCount)
+			counts := make([]float64, m.labelYCount)
+			for _, sample := range samples[start:end] {
+				m.scores(sample.GetDataVec(), tmpSum, counts)
+				predicted := 0
+				for li := range tmpSum {
+					if tmpSum[li] > tmpSum[predicted] {
+						predicted = li
+					}
+				}
+				// p(y|x) from the same scores, shifted by the max against overflow
+				Zw := 0.0
+				for li := range tmpSum {
+					Zw += math.Exp(tmpSum[li] - tmpSum[predicted])
+				}
+				trueProb := 0.0
+				if label := sample.GetLabel(); label < m.labelYCount {
+					trueProb = math.Exp(tmpSum[label]-tmpSum[predicted]) / Zw
+				}
+				multiclass.Add(predicted, sample.GetLabel(), trueProb)
+			}
+		})
 }
 
 func (m *MaxEntIIS) Validation() {
-	top1Hit := 0
 	vCount := len(m.train[:10000])
-	for i := 0; i < vCount; i++ {
-		if m.Predict(m.test[i]) {
-			top1Hit += 1
-		}
-	}
-	fmt.Println("validation accuracy：", float64(top1Hit)/float64(vCount))
+	logging.Info("validation", "accuracy", m.Evaluate(m.test[:vCount])[metrics.Accuracy])
 }
 
-func (m *MaxEntIIS) ComputeLH() {
+// ComputeLH is the mean log likelihood of the training samples at the
+// weights of the last E-step.
+func (m *MaxEntIIS) ComputeLH() float64 {
 	result := 0.0
 	for i := 0; i < len(m.train); i++ {
 		result += math.Log(m.allPwXy[i])
 	}
 	result = result / m.probX
-	fmt.Println("likehood ", result)
-
+	logging.Debug("likelihood", "value", result)
+	return result
 }
 func (m *MaxEntIIS) Test() {
-	top1Hit := 0
-	testCount := len(m.test)
-	for i := 0; i < testCount; i++ {
-		if m.Predict(m.test[i]) {
-			top1Hit += 1
-		}
-	}
-	fmt.Println("test accuracy：", float64(top1Hit)/float64(testCount))
+	logging.Info("test", "accuracy", m.Evaluate(m.test)[metrics.Accuracy])
 }
 
+// SaveModel saves to DefaultModelPath, use Save for another path.
 func (m *MaxEntIIS) SaveModel() {
-	fileName := fmt.Sprintf("./last_model.dat")
-	if f, err := os.Create(fileName); err == nil {
-		defer f.Close()
-		if content, err := json.Marshal(m.featureArray); err == nil {
-			if _, err := f.Write(content); err != nil {
-				fmt.Println(err.Error())
-			}
-		}
+	if err := m.Save(DefaultModelPath); err != nil {
+		logging.Error("save model", "path", DefaultModelPath, "err", err)
 	}
 }
 
+// LoadModel loads DefaultModelPath, use Load or LoadMaxEnt for another path.
 func (m *MaxEntIIS) LoadModel() bool {
-	fileName := fmt.Sprintf("./last_model.dat")
-	if dat, err := ioutil.ReadFile(fileName); err == nil {
-		if err := json.Unmarshal(dat, &m.featureArray); err == nil {
-			return true
-		} else {
-			fmt.Println(err.Error())
-		}
-	} else {
-		fmt.Println(err.Error())
+	if err := m.Load(DefaultModelPath); err != nil {
+		logging.Error("load model", "path", DefaultModelPath, "err", err)
+		return false
 	}
-	return false
+	return true
 }
 
 func (m *MaxEntIIS) calcAllPwXY() {
@@ -250,9 +363,28 @@ func (m *MaxEntIIS) calcAllPwXY() {
 	}
 }
 
-func (m *MaxEntIIS) calcAllPwXYParallel(coreNum int) {
+// calcAllPwXYParallel is calcAllPwXYV2 over coreNum chunks of the training
+// samples. Every worker sums into buffers of its own, the buffers are added
+// in chunk order afterwards. Histogram buffers are reused by later calls.
+func (m *MaxEntIIS) calcAllPwXYParallel(coreNum int, histogram []float64) float64 {
 	trainLen := len(m.train)
+	if coreNum > trainLen {
+		coreNum = trainLen
+	}
+	if coreNum <= 1 {
+		return m.calcAllPwXYV2(histogram)
+	}
 	batchSize := trainLen / coreNum
+	buffers := make([][]float64, coreNum)
+	if histogram != nil && (len(m.workerHistograms) != coreNum || len(m.workerHistograms[0]) != len(histogram)) {
+		m.workerHistograms = make([][]float64, coreNum)
+		for i := range m.workerHistograms {
+			m.workerHistograms[i] = make([]float64, len(histogram))
+		}
+	}
+	histograms := make([][]float64, coreNum)
+	nlls := make([]float64, coreNum)
+	corrections := make([]float64, coreNum)
 	wg := sync.WaitGroup{}
 	wg.Add(coreNum)
 	for i := 0; i < coreNum; i++ {
@@ -260,53 +392,185 @@ func (m *MaxEntIIS) calcAllPwXYParallel(coreNum int) {
 		if i == coreNum-1 {
 			indEnd = trainLen
 		}
-		go func(start, end int, notify *sync.WaitGroup) {
-			defer notify.Done()
-			for i, item := range m.train[start:end] {
-				m.allPwXy[i] = m.ComputePwXY(item)
+		go func(worker, start, end int) {
+			defer wg.Done()
+			buffers[worker] = make([]float64, m.featureFuncLen)
+			if histogram != nil {
+				histograms[worker] = m.workerHistograms[worker]
+				for k := range histograms[worker] {
+					histograms[worker][k] = 0
+				}
 			}
-		}(i*batchSize, indEnd, &wg)
+			nlls[worker], corrections[worker] = m.expectRange(start, end, buffers[worker], histograms[worker])
+		}(i, i*batchSize, indEnd)
+	}
+	wg.Wait()
+
+	for fi := range m.featureExp {
+		m.featureExp[fi] = 0
+	}
+	for k := range histogram {
+		histogram[k] = 0
+	}
+	nll, correction := 0.0, 0.0
+	for i, buffer := range buffers {
+		for fi, exp := range buffer {
+			m.featureExp[fi] += exp
+		}
+		for k, exp := range histograms[i] {
+			histogram[k] += exp
+		}
+		nll += nlls[i]
+		correction += corrections[i]
 	}
+	m.trainLoss = nll / float64(trainLen)
+	return correction
 }
 
-func (m *MaxEntIIS) calcAllPwXYV2() {
+// calcAllPwXYV2 fills featureExp, allPwXy and trainLoss for the current
+// weights and returns the model sum of the correction feature. A histogram,
+// when given, is filled as by ExpectHistogram.
+func (m *MaxEntIIS) calcAllPwXYV2(histogram []float64) float64 {
+	for fi := range m.featureExp {
+		m.featureExp[fi] = 0
+	}
+	for k := range histogram {
+		histogram[k] = 0
+	}
+	nll, correction := m.expectRange(0, len(m.train), m.featureExp, histogram)
+	m.trainLoss = nll / float64(len(m.train))
+	return correction
+}
 
+// expectRange adds the model expectation over m.train[start:end] to
+// featureExp and histogram, when not nil, and fills allPwXy of those samples.
+// It returns their negative log likelihood and model sum of the correction
+// feature.
+func (m *MaxEntIIS) expectRange(start, end int, featureExp, histogram []float64) (float64, float64) {
+	nll, correction := 0.0, 0.0
 	pwTmp := make([]float64, m.labelYCount)
-	for _, item := range m.train {
+	counts := make([]float64, m.labelYCount)
+	ranks := make([]int32, m.labelYCount)
+	for i := start; i < end; i++ {
+		item := m.train[i]
 		/**
 		相比Version 1 只遍历样本中出现的x，y对，
 		version 2 最大的修改为遍历训练数据的所有x，并配对所有的y进行计算
 		*/
-		for li := 0; li < m.labelYCount; li++ {
-			pwTmp[li] = m.ComputePwXYV2(item.GetDataVec(), li)
+		m.scores(item.GetDataVec(), pwTmp, counts)
+		probabilities(pwTmp)
+		m.allPwXy[i] = pwTmp[item.GetLabel()]
+		nll += metrics.NegLog(m.allPwXy[i])
+		for li, pw := range pwTmp {
+			correction += pw * (m.Bound() - counts[li])
+			if histogram != nil {
+				ranks[li] = int32(m.sumIndex[counts[li]])
+			}
 		}
 
-		for fi, feature := range m.featureArray {
-			for i, pw := range pwTmp {
-				if feature.LabelIndex == i && feature.XDValue == item.GetDataByIndex(feature.XDIndex) {
-					/**
-					  将(1 / m.probX)相乘提出放到外面，可以节省计算
-					*/
-					//m.allPwXy[fi] += (1 / m.probX) * pw
-					m.allPwXy[fi] += pw
+		// only the active features of the sample, O(xDimension * labels)
+		// instead of a scan of the whole featureArray
+		for j, v := range item.GetDataVec() {
+			s := m.active(j, v)
+			for fi := int(s.start); fi < int(s.end); fi++ {
+				/**
+				  将(1 / m.probX)相乘提出放到外面，可以节省计算
+				*/
+				featureExp[fi] += pwTmp[m.featureLabel[fi]]
+				if histogram != nil {
+					histogram[m.layout.entry(fi, ranks[m.featureLabel[fi]])] += pwTmp[m.featureLabel[fi]]
 				}
 			}
 		}
 	}
+	return nll, correction
 }
 
+// TestIter runs a single IIS iteration.
 func (m *MaxEntIIS) TestIter() {
-	m.calcAllPwXYV2()
+	solver, _ := NewSolver(SolverIIS, m.Variance)
+	weights := append([]float64(nil), m.weights.Float64s()...)
+	solver.Iterate(m, weights)
+}
 
-	deltaList := make([]float64, m.featureFuncLen)
-	for fi, feature := range m.featureArray {
-		/**
-		将calcAllPwXYV2中计算的 m.allPwXy[fi] * 1 / m.probX提出放到这里
-		*/
-		//deltaList[fi] = math.Log((feature.Prob)/m.allPwXy[fi]) * m.M
-		deltaList[fi] = math.Log((feature.Prob*m.probX)/m.allPwXy[fi]) * m.M
+// FeatureCount, SampleCount, Bound, Observed, SetWeights and Expect make
+// the model a Problem. The observed sum is the feature count, E~[f] * N;
+// the scaling step of the first version compared Prob * probX, which is
+// the count divided by xDimension, against it.
+
+func (m *MaxEntIIS) FeatureCount() int {
+	return m.featureFuncLen
+}
+
+func (m *MaxEntIIS) SampleCount() int {
+	return m.N
+}
+
+// Bound is xDimension, every pixel matches at most one feature per label.
+func (m *MaxEntIIS) Bound() float64 {
+	return float64(m.xDimension)
+}
+
+func (m *MaxEntIIS) Observed() ([]float64, float64) {
+	return m.observed, m.observedCorrection
+}
+
+func (m *MaxEntIIS) SetWeights(weights []float64, correction float64) {
+	for fi, w := range weights {
+		m.weights.Set(fi, w)
 	}
-	for fi := 0; fi < m.featureFuncLen; fi++ {
-		m.featureArray[fi].Weight += deltaList[fi]
+	m.correction = correction
+}
+
+func (m *MaxEntIIS) Expect(expected []float64) (float64, float64) {
+	correction := m.calcAllPwXYParallel(m.coreNum, nil)
+	copy(expected, m.featureExp)
+	return correction, m.trainLoss * float64(m.N)
+}
+
+// FeatureSums and ExpectHistogram let IIS take the exact step, f#(x, y) is
+// the number of matching features and varies once a cutoff drops some or
+// a label never saw a pixel value.
+
+func (m *MaxEntIIS) FeatureSums() ([]int, []float64) {
+	if m.layout == nil {
+		m.sums = nil
+		m.sumIndex = make(map[float64]int)
+		tmpSum := make([]float64, m.labelYCount)
+		counts := make([]float64, m.labelYCount)
+		for _, item := range m.train {
+			m.scores(item.GetDataVec(), tmpSum, counts)
+			for _, count := range counts {
+				if _, ok := m.sumIndex[count]; !ok {
+					m.sumIndex[count] = len(m.sums)
+					m.sums = append(m.sums, count)
+				}
+			}
+		}
+		// the histogram only gets the pairs of a feature and the f# of the
+		// samples it is active in, far fewer than features * sums
+		pairs := newPairSet(m.featureFuncLen, len(m.sums))
+		ranks := make([]int, m.labelYCount)
+		for _, item := range m.train {
+			m.scores(item.GetDataVec(), tmpSum, counts)
+			for li, count := range counts {
+				ranks[li] = m.sumIndex[count]
+			}
+			for j, v := range item.GetDataVec() {
+				s := m.active(j, v)
+				for fi := int(s.start); fi < int(s.end); fi++ {
+					pairs.add(fi, ranks[m.featureLabel[fi]])
+				}
+			}
+		}
+		m.layout = pairs.layout(m.featureFuncLen, m.sums)
 	}
+	return m.layout.offsets, m.layout.sums
+}
+
+func (m *MaxEntIIS) ExpectHistogram(expected, histogram []float64) (float64, float64) {
+	m.FeatureSums()
+	correction := m.calcAllPwXYParallel(m.coreNum, histogram)
+	copy(expected, m.featureExp)
+	return correction, m.trainLoss * float64(m.N)
 }
@@ -0,0 +1,260 @@
+package IIS
+
+import (
+	"math"
+	"math/rand"
+	"maxent/dataformat"
+	"metrics"
+	"reflect"
+	"testing"
+)
+
+// digits draws binary images whose pixels are more often on at the
+// positions of their own label.
+func digits(n, pixels, labels int, seed int64) []*data.MnistSample {
+	r := rand.New(rand.NewSource(seed))
+	samples := make([]*data.MnistSample, n)
+	for i := range samples {
+		label := r.Intn(labels)
+		dataVec := make([]uint8, pixels)
+		for j := range dataVec {
+			on := 0.2
+			if j%labels == label {
+				on = 0.8
+			}
+			if r.Float64() < on {
+				dataVec[j] = 1
+			}
+		}
+		samples[i] = data.NewMnistSample(dataVec, label)
+	}
+	return samples
+}
+
+// scanScores is the full featureArray scan the index replaced.
+func scanScores(m *MaxEntIIS, dataVec []uint8) []float64 {
+	tmpSum := make([]float64, m.labelYCount)
+	for fi, feature := range m.featureArray {
+		if feature.XDValue == dataVec[feature.XDIndex] {
+			tmpSum[feature.LabelIndex] += m.weights.At(fi)
+		}
+	}
+	return tmpSum
+}
+
+func TestIndexMatchesScan(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(300, 24, 4, 1), digits(100, 24, 4, 2), 4)
+	m.StartTraining(3, 1)
+
+	tmpSum := make([]float64, m.labelYCount)
+	counts := make([]float64, m.labelYCount)
+	for _, sample := range m.test {
+		m.scores(sample.GetDataVec(), tmpSum, counts)
+		want := scanScores(m, sample.GetDataVec())
+		for li := range want {
+			if math.Abs(tmpSum[li]-want[li]) > 1e-9 {
+				t.Fatalf("label %d score %v, scan %v", li, tmpSum[li], want[li])
+			}
+		}
+	}
+}
+
+func closeSlices(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestParallelExpectationMatchesSerial(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(203, 24, 4, 1), digits(10, 24, 4, 2), 4)
+	// exercise the correction feature as well
+	m.correction = 0.05
+
+	correction := m.calcAllPwXYV2(nil)
+	featureExp := append([]float64(nil), m.featureExp...)
+	allPwXy := append([]float64(nil), m.allPwXy...)
+	trainLoss := m.trainLoss
+	for _, coreNum := range []int{2, 3, 8, 500} {
+		for fi := range m.featureExp {
+			m.featureExp[fi] = -1
+		}
+		for i := range m.allPwXy {
+			m.allPwXy[i] = -1
+		}
+		got := m.calcAllPwXYParallel(coreNum, nil)
+		if math.Abs(got-correction) > 1e-9 || math.Abs(m.trainLoss-trainLoss) > 1e-9 {
+			t.Fatalf("%d cores: correction %v loss %v, serial %v %v", coreNum, got, m.trainLoss, correction, trainLoss)
+		}
+		closeSlices(t, "featureExp", m.featureExp, featureExp)
+		closeSlices(t, "allPwXy", m.allPwXy, allPwXy)
+	}
+}
+
+func TestParallelTrainingMatchesSerial(t *testing.T) {
+	train, test := digits(300, 24, 4, 1), digits(50, 24, 4, 2)
+	serial, parallel := &MaxEntIIS{}, &MaxEntIIS{}
+	serial.SetData(train, test, 4)
+	parallel.SetData(train, test, 4)
+	parallel.SetWeights(append([]float64(nil), serial.weights.Float64s()...), 0)
+
+	serial.StartTraining(5, 1)
+	parallel.StartTraining(5, 4)
+	closeSlices(t, "weights", parallel.weights.Float64s(), serial.weights.Float64s())
+}
+
+func BenchmarkMaxEntIteration(b *testing.B) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(1000, 784, 10, 1), digits(10, 784, 10, 2), 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.TestIter()
+	}
+}
+
+func TestCutoffDropsRareFeatures(t *testing.T) {
+	train, test := digits(200, 24, 4, 1), digits(50, 24, 4, 2)
+	all := &MaxEntIIS{}
+	all.SetData(train, test, 4)
+	m := &MaxEntIIS{Cutoff: 20, Solver: SolverGIS}
+	m.SetData(train, test, 4)
+	if m.featureFuncLen >= all.featureFuncLen {
+		t.Fatalf("%d features with cutoff, %d without", m.featureFuncLen, all.featureFuncLen)
+	}
+	for _, feature := range m.featureArray {
+		if feature.Count < m.Cutoff {
+			t.Fatalf("feature %s seen %d times kept", feature.FeatureKey, feature.Count)
+		}
+	}
+	if m.observedCorrection <= 0 {
+		t.Fatalf("observed correction %v with dropped features", m.observedCorrection)
+	}
+	m.StartTraining(20, 1)
+	if accuracy := m.Evaluate(test)[metrics.Accuracy]; accuracy < 0.9 {
+		t.Errorf("accuracy %v", accuracy)
+	}
+}
+
+func TestParallelHistogramMatchesSerial(t *testing.T) {
+	m := &MaxEntIIS{Cutoff: 20}
+	m.SetData(digits(203, 24, 4, 1), digits(10, 24, 4, 2), 4)
+	offsets, sums := m.FeatureSums()
+	if len(m.sums) < 2 {
+		t.Fatalf("f# takes %d values with a cutoff", len(m.sums))
+	}
+	if len(sums) >= len(m.sums)*m.featureFuncLen {
+		t.Errorf("%d histogram entries, dense is %d", len(sums), len(m.sums)*m.featureFuncLen)
+	}
+	serial := make([]float64, len(sums))
+	m.calcAllPwXYV2(serial)
+	// every feature's histogram adds up to its expectation
+	for fi := 0; fi < m.featureFuncLen; fi++ {
+		total := 0.0
+		for _, e := range serial[offsets[fi]:offsets[fi+1]] {
+			total += e
+		}
+		if math.Abs(total-m.featureExp[fi]) > 1e-9 {
+			t.Fatalf("feature %d histogram sums to %v, expectation %v", fi, total, m.featureExp[fi])
+		}
+	}
+	parallel := make([]float64, len(serial))
+	m.calcAllPwXYParallel(3, parallel)
+	closeSlices(t, "histogram", parallel, serial)
+}
+
+func TestToleranceStopsTraining(t *testing.T) {
+	m := &MaxEntIIS{Tolerance: 1e-3, Variance: 1}
+	m.SetData(digits(200, 24, 4, 1), digits(50, 24, 4, 2), 4)
+	m.StartTraining(500, 1)
+	if m.iterations == 0 || m.iterations >= 500 {
+		t.Errorf("stopped after %d iterations", m.iterations)
+	}
+}
+
+// mnistSized has 784 pixels and 10 labels, label y only inks the first
+// 78 * (y + 1) pixels so f#(x, y) spreads over hundreds of values like MNIST.
+func mnistSized(n int, seed int64) []*data.MnistSample {
+	r := rand.New(rand.NewSource(seed))
+	samples := make([]*data.MnistSample, n)
+	for i := range samples {
+		label := r.Intn(10)
+		density := r.Float64()
+		dataVec := make([]uint8, 784)
+		for j := 0; j < 78*(label+1) && j < len(dataVec); j++ {
+			if r.Float64() < density {
+				dataVec[j] = 1
+			}
+		}
+		samples[i] = data.NewMnistSample(dataVec, label)
+	}
+	return samples
+}
+
+func TestMnistSizedProblemTakesExactStep(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(mnistSized(1000, 1), mnistSized(10, 2), 10)
+	m.coreNum = 4
+	offsets, sums := m.FeatureSums()
+	dense := len(m.sums) * m.featureFuncLen
+	t.Logf("%d features, %d f# values, %d histogram entries of %d", m.featureFuncLen, len(m.sums), len(sums), dense)
+	if dense <= 1<<22 {
+		t.Fatalf("dense histogram %d is smaller than MNIST", dense)
+	}
+	if len(offsets) != m.featureFuncLen+1 || offsets[m.featureFuncLen] != len(sums) {
+		t.Fatalf("offsets %d ending at %d for %d entries", len(offsets), offsets[len(offsets)-1], len(sums))
+	}
+
+	solver, _ := NewSolver(SolverIIS, 0)
+	weights := make([]float64, m.FeatureCount())
+	first, err := solver.Iterate(m, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solver.(*scaling).histogram == nil {
+		t.Fatal("IIS fell back to the bound step")
+	}
+	second, err := solver.Iterate(m, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second >= first {
+		t.Errorf("loss %v after one step, %v before", second, first)
+	}
+}
+
+func TestPairSetMapMatchesBitset(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	features, ranks := 50, 7
+	distinct := []float64{3, 1, 4, 1.5, 9, 2.6, 5}
+	bitset := newPairSet(features, ranks)
+	set := &pairSet{ranks: ranks, set: make(map[int]bool)}
+	for i := 0; i < 200; i++ {
+		f, rank := r.Intn(features), r.Intn(ranks)
+		bitset.add(f, rank)
+		set.add(f, rank)
+	}
+	a, b := bitset.layout(features, distinct), set.layout(features, distinct)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("bitset layout %v, map layout %v", a, b)
+	}
+	for f := 0; f < features; f++ {
+		for k := a.offsets[f]; k < a.offsets[f+1]; k++ {
+			if k > a.offsets[f] && a.ranks[k] <= a.ranks[k-1] {
+				t.Fatalf("feature %d ranks not ascending %v", f, a.ranks[a.offsets[f]:a.offsets[f+1]])
+			}
+			if a.sums[k] != distinct[a.ranks[k]] {
+				t.Fatalf("entry %d sum %v, rank %d", k, a.sums[k], a.ranks[k])
+			}
+			if e := a.entry(f, a.ranks[k]); e != k {
+				t.Fatalf("feature %d rank %d at %d, want %d", f, a.ranks[k], e, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package IIS
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"maxent/dataformat"
+	"os"
+	"param"
+	"sort"
+	"time"
+)
+
+const (
+	// ModelVersion is written into every saved MaxEntIIS. Load rejects newer
+	// files and reads version 0, the bare feature array of the first SaveModel.
+	ModelVersion     int    = 2
+	DefaultModelPath string = "./last_model.dat"
+)
+
+// Schema is the input a model scores, XDimension pixels discretized by
+// Discretizer.
+type Schema struct {
+	XDimension  int
+	Discretizer data.Discretizer
+	// PixelThreshold is the binarization of version 1, Load turns it into
+	// Discretizer
+	PixelThreshold int `json:",omitempty"`
+}
+
+// Training records how a saved model was trained.
+type Training struct {
+	Solver     string  `json:",omitempty"`
+	Variance   float64 `json:",omitempty"`
+	Cutoff     int     `json:",omitempty"`
+	Iterations int
+	Samples    int
+	// Saved is the RFC 3339 time of Save
+	Saved string
+}
+
+type modelFile struct {
+	Version     int
+	LabelYCount int
+	Schema      Schema
+	Training    Training
+	Precision   string  `json:",omitempty"`
+	Correction  float64 `json:",omitempty"`
+	Features    FeatureList
+}
+
+func (m *MaxEntIIS) Schema() Schema {
+	return Schema{XDimension: m.xDimension, Discretizer: m.Discretizer}
+}
+
+// Discretize maps raw pixels the way the training data was read.
+func (m *MaxEntIIS) Discretize(pixels []uint8) []uint8 {
+	return m.Discretizer.Apply(pixels)
+}
+
+// Save writes the features with their weights, the label count, the input
+// schema and the training settings, enough to score without training data.
+func (m *MaxEntIIS) Save(path string) error {
+	for fi, feature := range m.featureArray {
+		feature.Weight = m.weights.At(fi)
+	}
+	content, err := json.Marshal(modelFile{
+		Version:     ModelVersion,
+		LabelYCount: m.labelYCount,
+		Schema:      m.Schema(),
+		Training: Training{Solver: m.Solver, Variance: m.Variance, Cutoff: m.Cutoff,
+			Iterations: m.iterations, Samples: m.N, Saved: time.Now().Format(time.RFC3339)},
+		Precision:  m.Precision,
+		Correction: m.correction,
+		Features:   m.featureArray,
+	})
+	if err != nil {
+		return err
+	}
+	// write aside and rename, readers never see a half written model
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func LoadMaxEnt(path string) (*MaxEntIIS, error) {
+	m := &MaxEntIIS{}
+	if err := m.Load(path); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Load replaces the model by the one saved at path, m.Precision overrides
+// the saved precision when set.
+func (m *MaxEntIIS) Load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := modelFile{}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		// version 0 saved only the features, the rest follows from them
+		if err := json.Unmarshal(content, &file.Features); err != nil {
+			return err
+		}
+		file.Schema.PixelThreshold = data.PixelThreshold
+		for _, feature := range file.Features {
+			if feature.LabelIndex >= file.LabelYCount {
+				file.LabelYCount = feature.LabelIndex + 1
+			}
+			if feature.XDIndex >= file.Schema.XDimension {
+				file.Schema.XDimension = feature.XDIndex + 1
+			}
+		}
+	} else if err := json.Unmarshal(content, &file); err != nil {
+		return err
+	} else if file.Version > ModelVersion {
+		return fmt.Errorf("%s: model version %d, this build reads up to %d", path, file.Version, ModelVersion)
+	}
+	if file.Schema.PixelThreshold > 0 {
+		file.Schema.Discretizer = data.Threshold(float64(file.Schema.PixelThreshold))
+		file.Schema.PixelThreshold = 0
+	}
+	for _, feature := range file.Features {
+		if feature.LabelIndex < 0 || feature.LabelIndex >= file.LabelYCount ||
+			feature.XDIndex < 0 || feature.XDIndex >= file.Schema.XDimension {
+			return fmt.Errorf("%s: feature %s outside %d labels and %d pixels",
+				path, feature.FeatureKey, file.LabelYCount, file.Schema.XDimension)
+		}
+	}
+
+	if m.Precision == "" {
+		m.Precision = file.Precision
+	}
+	m.labelYCount = file.LabelYCount
+	m.xDimension = file.Schema.XDimension
+	m.Discretizer = file.Schema.Discretizer
+	m.M = 1.0 / float64(m.xDimension)
+	m.Solver, m.Variance, m.Cutoff = file.Training.Solver, file.Training.Variance, file.Training.Cutoff
+	m.iterations, m.N = file.Training.Iterations, file.Training.Samples
+	m.correction = file.Correction
+
+	m.featureArray = file.Features
+	sort.Sort(m.featureArray)
+	m.featureFuncLen = len(m.featureArray)
+	m.buildIndex()
+	m.weights = param.NewVector(m.featureFuncLen, m.Precision)
+	for fi, feature := range m.featureArray {
+		m.weights.Set(fi, feature.Weight)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package IIS
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"maxent/dataformat"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tempModelPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "maxent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "maxent.model"), func() { os.RemoveAll(dir) }
+}
+
+func TestSaveLoadScoresWithoutTrainingData(t *testing.T) {
+	train, test := digits(300, 24, 4, 1), digits(100, 24, 4, 2)
+	m := &MaxEntIIS{Solver: SolverGIS, Cutoff: 20, Precision: "float32"}
+	m.SetData(train, test, 4)
+	m.StartTraining(10, 2)
+	if m.correction == 0 {
+		t.Fatal("GIS with dropped features left the correction at 0")
+	}
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadMaxEnt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Schema(), m.Schema()) || loaded.Precision != "float32" || loaded.labelYCount != 4 {
+		t.Fatalf("loaded schema %+v precision %s labels %d", loaded.Schema(), loaded.Precision, loaded.labelYCount)
+	}
+	if loaded.iterations != 10 || loaded.Solver != SolverGIS || loaded.Cutoff != 20 || loaded.N != 300 {
+		t.Errorf("training metadata %d %s %d %d", loaded.iterations, loaded.Solver, loaded.Cutoff, loaded.N)
+	}
+	for _, sample := range test {
+		if got, want := loaded.Classify(sample.GetDataVec()), m.Classify(sample.GetDataVec()); got != want {
+			t.Fatalf("loaded model says %d, trained %d", got, want)
+		}
+	}
+	closeSlices(t, "weights", loaded.weights.Float64s(), m.weights.Float64s())
+	if got, want := loaded.Evaluate(test), m.Evaluate(test); got.String() != want.String() {
+		t.Errorf("loaded %s, trained %s", got.String(), want.String())
+	}
+}
+
+func TestLoadVersionZero(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(200, 24, 4, 1), digits(50, 24, 4, 2), 4)
+	m.StartTraining(5, 1)
+	for fi, feature := range m.featureArray {
+		feature.Weight = m.weights.At(fi)
+	}
+	// the first SaveModel wrote the feature array only
+	content, err := json.Marshal(m.featureArray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMaxEnt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Schema(), Schema{XDimension: 24, Discretizer: data.Binary()}) || loaded.labelYCount != 4 {
+		t.Fatalf("schema %+v labels %d", loaded.Schema(), loaded.labelYCount)
+	}
+	for _, sample := range m.test {
+		if got, want := loaded.Classify(sample.GetDataVec()), m.Classify(sample.GetDataVec()); got != want {
+			t.Fatalf("loaded model says %d, trained %d", got, want)
+		}
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	content := []byte(`{"Version": 99, "LabelYCount": 2, "Schema": {"XDimension": 1}, "Features": []}`)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMaxEnt(path); err == nil {
+		t.Error("no error for a newer model version")
+	}
+}
+
+func TestLoadVersionOneThreshold(t *testing.T) {
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	content := []byte(`{"Version": 1, "LabelYCount": 2, "Schema": {"XDimension": 1, "PixelThreshold": 100}, "Features": []}`)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMaxEnt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Discretize([]uint8{100, 101}); got[0] != 0 || got[1] != 1 {
+		t.Errorf("version 1 threshold 100 discretizes 100, 101 to %v", got)
+	}
+}
+
+// pixelRows draws raw pixels, pixel j of label y is around 40 * (j + y) % 256.
+func pixelRows(n, pixels, labels int, seed int64) []data.PixelRow {
+	r := rand.New(rand.NewSource(seed))
+	rows := make([]data.PixelRow, n)
+	for i := range rows {
+		rows[i].Label = r.Intn(labels)
+		rows[i].Pixels = make([]uint8, pixels)
+		for j := range rows[i].Pixels {
+			pixel := 40*(j+rows[i].Label)%256 + r.Intn(61) - 30
+			if pixel < 0 {
+				pixel = 0
+			} else if pixel > 255 {
+				pixel = 255
+			}
+			rows[i].Pixels[j] = uint8(pixel)
+		}
+	}
+	return rows
+}
+
+func TestSaveLoadQuantileDiscretizer(t *testing.T) {
+	trainRows, testRows := pixelRows(300, 12, 3, 1), pixelRows(100, 12, 3, 2)
+	d, err := data.NewDiscretizer(data.DiscretizeQuantile, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Fit(trainRows)
+	m := &MaxEntIIS{Discretizer: d}
+	m.SetData(d.Samples(trainRows), d.Samples(testRows), 3)
+	m.StartTraining(5, 1)
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMaxEnt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Schema(), m.Schema()) {
+		t.Fatalf("loaded schema %+v, saved %+v", loaded.Schema(), m.Schema())
+	}
+	for _, row := range testRows {
+		if got, want := loaded.Classify(loaded.Discretize(row.Pixels)), m.Classify(d.Apply(row.Pixels)); got != want {
+			t.Fatalf("loaded model says %d on raw pixels, trained %d", got, want)
+		}
+	}
+}
+
+func writeMnistCsv(t *testing.T, path string, rows []data.PixelRow) {
+	var lines []string
+	for _, row := range rows {
+		line := []string{strconv.Itoa(row.Label)}
+		for _, pixel := range row.Pixels {
+			line = append(line, strconv.Itoa(int(pixel)))
+		}
+		lines = append(lines, strings.Join(line, ","))
+	}
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDataFitsOnTrainingFile(t *testing.T) {
+	trainRows, testRows := pixelRows(300, 12, 3, 1), pixelRows(100, 12, 3, 2)
+	// the test file is darker, cuts fitted on it would differ
+	for _, row := range testRows {
+		for j := range row.Pixels {
+			row.Pixels[j] /= 4
+		}
+	}
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	trainPath, testPath := path+".train.csv", path+".test.csv"
+	writeMnistCsv(t, trainPath, trainRows)
+	writeMnistCsv(t, testPath, testRows)
+
+	d, err := data.NewDiscretizer(data.DiscretizeQuantile, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &MaxEntIIS{Discretizer: d}
+	if err := m.LoadData(trainPath, testPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.train) != len(trainRows) || len(m.test) != len(testRows) {
+		t.Fatalf("%d training and %d test samples", len(m.train), len(m.test))
+	}
+	d.Fit(trainRows)
+	if !reflect.DeepEqual(m.Discretizer.PixelCuts, d.PixelCuts) {
+		t.Errorf("cuts %v, fitted on the training file %v", m.Discretizer.PixelCuts, d.PixelCuts)
+	}
+}
+
+func TestLoadDataMissingOrEmptyFile(t *testing.T) {
+	path, cleanup := tempModelPath(t)
+	defer cleanup()
+	trainPath, emptyPath := path+".train.csv", path+".empty.csv"
+	writeMnistCsv(t, trainPath, pixelRows(20, 12, 3, 1))
+	if err := ioutil.WriteFile(emptyPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &MaxEntIIS{}
+	for _, paths := range [][2]string{
+		{path + ".missing.csv", trainPath},
+		{emptyPath, trainPath},
+		{trainPath, emptyPath},
+	} {
+		if err := m.LoadData(paths[0], paths[1]); err == nil {
+			t.Errorf("LoadData(%s, %s) without error", paths[0], paths[1])
+		}
+	}
+	if err := m.SetData(nil, nil, 3); err == nil {
+		t.Error("SetData without training samples")
+	}
+}
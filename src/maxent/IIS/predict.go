@@ -0,0 +1,61 @@
+package IIS
+
+import (
+	"math"
+	"sort"
+)
+
+// LabelScore is a label with its probability p(y|x).
+type LabelScore struct {
+	Label int
+	Prob  float64
+}
+
+// Probabilities returns p(y|x) of every label for binarized pixels, the
+// sample needs no label.
+func (m *MaxEntIIS) Probabilities(dataVec []uint8) []float64 {
+	probs := make([]float64, m.labelYCount)
+	m.scores(dataVec, probs, make([]float64, m.labelYCount))
+	probabilities(probs)
+	return probs
+}
+
+// LogProbabilities returns log p(y|x) of every label, computed from the
+// scores so labels far below the best stay finite.
+func (m *MaxEntIIS) LogProbabilities(dataVec []uint8) []float64 {
+	logProbs := make([]float64, m.labelYCount)
+	m.scores(dataVec, logProbs, make([]float64, m.labelYCount))
+	maxWeight := math.Inf(-1)
+	for _, s := range logProbs {
+		maxWeight = math.Max(maxWeight, s)
+	}
+	Zw := 0.0
+	for _, s := range logProbs {
+		Zw += math.Exp(s - maxWeight)
+	}
+	logZ := maxWeight + math.Log(Zw)
+	for li := range logProbs {
+		logProbs[li] -= logZ
+	}
+	return logProbs
+}
+
+// TopK returns the k most probable labels, best first, ties in label order.
+// k beyond the label count returns every label.
+func (m *MaxEntIIS) TopK(dataVec []uint8, k int) []LabelScore {
+	probs := m.Probabilities(dataVec)
+	ranked := make([]LabelScore, len(probs))
+	for li, p := range probs {
+		ranked[li] = LabelScore{Label: li, Prob: p}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Prob > ranked[j].Prob
+	})
+	if k < 0 {
+		k = 0
+	}
+	if k < len(ranked) {
+		ranked = ranked[:k]
+	}
+	return ranked
+}
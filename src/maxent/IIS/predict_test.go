@@ -0,0 +1,58 @@
+package IIS
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProbabilityAPI(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(300, 24, 4, 1), digits(50, 24, 4, 2), 4)
+	m.StartTraining(10, 1)
+
+	for _, sample := range m.test {
+		dataVec := sample.GetDataVec()
+		probs := m.Probabilities(dataVec)
+		logProbs := m.LogProbabilities(dataVec)
+		sum := 0.0
+		for li, p := range probs {
+			sum += p
+			if math.Abs(math.Log(p)-logProbs[li]) > 1e-9 {
+				t.Fatalf("log p(%d|x) = %v, log of p = %v", li, logProbs[li], math.Log(p))
+			}
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Fatalf("probabilities sum to %v", sum)
+		}
+
+		top := m.TopK(dataVec, 3)
+		if len(top) != 3 || top[0].Label != m.Classify(dataVec) {
+			t.Fatalf("top %v, classify %d", top, m.Classify(dataVec))
+		}
+		for i, score := range top {
+			if score.Prob != probs[score.Label] || i > 0 && score.Prob > top[i-1].Prob {
+				t.Fatalf("top %v, probabilities %v", top, probs)
+			}
+		}
+	}
+	if got := len(m.TopK(m.test[0].GetDataVec(), 10)); got != 4 {
+		t.Errorf("top 10 of 4 labels has %d entries", got)
+	}
+}
+
+func TestLogProbabilitiesStayFinite(t *testing.T) {
+	m := &MaxEntIIS{}
+	m.SetData(digits(100, 24, 4, 1), digits(10, 24, 4, 2), 4)
+	weights := make([]float64, m.featureFuncLen)
+	for fi := range weights {
+		if m.featureLabel[fi] == 0 {
+			weights[fi] = 100
+		}
+	}
+	m.SetWeights(weights, 0)
+	for _, logProb := range m.LogProbabilities(m.test[0].GetDataVec()) {
+		if math.IsInf(logProb, 0) || math.IsNaN(logProb) {
+			t.Fatalf("log probabilities %v", m.LogProbabilities(m.test[0].GetDataVec()))
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package IIS
+
+import (
+	"errors"
+	"fmt"
+	"logging"
+	"math"
+)
+
+const (
+	SolverIIS   string = "iis"
+	SolverGIS   string = "gis"
+	SolverLBFGS string = "lbfgs"
+
+	lbfgsMemory      = 10
+	lineSearchTries  = 30
+	armijo           = 1e-4
+	curvatureEpsilon = 1e-10
+	newtonSteps      = 50
+	bracketSteps     = 100
+	// entries of the f# histogram, one per (feature, f#) pair that occurs,
+	// at most 128MB of float64 per E-step worker
+	maxHistogram = 1 << 24
+)
+
+// Problem is a conditional maxent model as seen by a Solver. Feature sums
+// run over the training samples, f#(x, y) is the feature sum of sample x
+// under label y.
+type Problem interface {
+	FeatureCount() int
+	SampleCount() int
+	// Bound is C >= f#(x, y) for every sample and label
+	Bound() float64
+	// Observed returns the empirical sum of every feature and of the GIS
+	// correction feature C - f#(x, y)
+	Observed() (features []float64, correction float64)
+	// SetWeights replaces the model weights, correction is the weight of
+	// the correction feature and 0 for solvers without it
+	SetWeights(weights []float64, correction float64)
+	// Expect fills expected with the model sum of every feature under the
+	// current weights, it returns the model sum of the correction feature
+	// and the negative log likelihood summed over the samples
+	Expect(expected []float64) (correction, nll float64)
+}
+
+// Histogram is implemented by problems whose f#(x, y) varies, IIS solves
+// its step exactly over the values of f# every feature occurs with.
+type Histogram interface {
+	// FeatureSums lays out the histogram, feature f owns the entries
+	// offsets[f]:offsets[f+1] and entry k stands for f#(x, y) = sums[k].
+	// Only the values of f# over the (x, y) where f is active get an entry,
+	// they do not depend on the weights
+	FeatureSums() (offsets []int, sums []float64)
+	// ExpectHistogram is Expect that also fills histogram[k] with the model
+	// sum of the feature of entry k over the (x, y) with f#(x, y) = sums[k]
+	ExpectHistogram(expected, histogram []float64) (correction, nll float64)
+}
+
+// Solver fits the weights of a Problem one iteration at a time.
+type Solver interface {
+	// Iterate updates weights and the model, it returns the mean negative
+	// log posterior seen in the iteration
+	Iterate(p Problem, weights []float64) (float64, error)
+	// Correction is the weight of the correction feature, 0 unless GIS
+	Correction() float64
+}
+
+// NewSolver puts a Gaussian prior with the given variance on every weight,
+// 0 trains without prior.
+func NewSolver(name string, variance float64) (Solver, error) {
+	switch name {
+	case "", SolverIIS:
+		return &scaling{variance: variance}, nil
+	case SolverGIS:
+		return &scaling{gis: true, variance: variance}, nil
+	case SolverLBFGS:
+		return &lbfgs{variance: variance}, nil
+	}
+	return nil, fmt.Errorf("unknown maxent solver %q", name)
+}
+
+// penalty is the negative log of the Gaussian prior up to a constant.
+func penalty(weights []float64, variance float64) float64 {
+	if variance <= 0 {
+		return 0
+	}
+	return dot(weights, weights) / (2 * variance)
+}
+
+// scaling is IIS, every weight moves by the delta solving
+// observed = sum over (x, y) of p(y|x) f(x, y) exp(delta * f#(x, y)).
+// Problems implementing Histogram get the exact solution, the others the
+// step log(observed / expected) / C of the constant bound C on f#(x, y).
+// With gis the correction feature makes f#(x, y) = C for every sample, which
+// is Generalized Iterative Scaling.
+type scaling struct {
+	gis        bool
+	variance   float64
+	correction float64
+	expected   []float64
+	// histogram[k] is the model sum of the feature owning entry k over the
+	// (x, y) with f#(x, y) = sums[k], nil for the bound step
+	histogram []float64
+	offsets   []int
+	sums      []float64
+	// prepared is set once the step is chosen
+	prepared bool
+}
+
+// delta is the step of weight w, expected[m] is the model sum of the feature
+// where f#(x, y) = sums[m]. It solves
+// observed = sum of expected[m] * exp(sums[m] * delta) + (w + delta) / variance
+// by Newton's method kept inside a bracket of the root, the prior term is
+// left out without variance and a single sum has the closed form.
+func (s *scaling) delta(w, observed float64, expected, sums []float64) float64 {
+	if len(expected) == 0 {
+		// a feature never active, only the prior moves it
+		if s.variance <= 0 {
+			return 0
+		}
+		return s.variance*observed - w
+	}
+	total, C := 0.0, 0.0
+	for m, e := range expected {
+		total += e
+		C = math.Max(C, sums[m])
+	}
+	if s.variance <= 0 {
+		if observed <= 0 || total <= 0 {
+			return 0
+		}
+		if len(sums) == 1 {
+			return math.Log(observed/total) / C
+		}
+	}
+	g := func(d float64) (float64, float64) {
+		v, dv := -observed, 0.0
+		for m, e := range expected {
+			if e > 0 {
+				e *= math.Exp(sums[m] * d)
+				v += e
+				dv += sums[m] * e
+			}
+		}
+		if s.variance > 0 {
+			v += (w + d) / s.variance
+			dv += 1 / s.variance
+		}
+		return v, dv
+	}
+	// g grows with d, widen the bracket until it changes sign
+	lo, hi := -1/C, 1/C
+	for i := 0; ; i++ {
+		if v, _ := g(lo); v <= 0 {
+			break
+		} else if i == bracketSteps {
+			return 0
+		}
+		lo *= 2
+	}
+	for i := 0; ; i++ {
+		if v, _ := g(hi); v >= 0 {
+			break
+		} else if i == bracketSteps {
+			return 0
+		}
+		hi *= 2
+	}
+	d := 0.0
+	for i := 0; i < newtonSteps; i++ {
+		v, dv := g(d)
+		if v > 0 {
+			hi = d
+		} else {
+			lo = d
+		}
+		next := d - v/dv
+		if !(next > lo && next < hi) {
+			next = (lo + hi) / 2
+		}
+		if math.Abs(next-d) < 1e-12 {
+			return next
+		}
+		d = next
+	}
+	return d
+}
+
+func (s *scaling) Correction() float64 {
+	return s.correction
+}
+
+// prepare picks the exact step when the problem has a histogram of a
+// bearable size, GIS has a constant f# and needs none.
+func (s *scaling) prepare(p Problem) {
+	s.prepared = true
+	s.expected = make([]float64, p.FeatureCount())
+	h, ok := p.(Histogram)
+	if s.gis || !ok {
+		return
+	}
+	offsets, sums := h.FeatureSums()
+	if len(sums) > maxHistogram {
+		logging.Warn("f# histogram too large, IIS uses the bound step", "entries", len(sums),
+			"features", p.FeatureCount())
+		return
+	}
+	s.offsets, s.sums = offsets, sums
+	s.histogram = make([]float64, len(sums))
+}
+
+func (s *scaling) Iterate(p Problem, weights []float64) (float64, error) {
+	if !s.prepared {
+		s.prepare(p)
+	}
+	C := p.Bound()
+	if C <= 0 {
+		return 0, errors.New("feature bound must be positive")
+	}
+	p.SetWeights(weights, s.correction)
+	var expectedCorrection, nll float64
+	if s.histogram != nil {
+		expectedCorrection, nll = p.(Histogram).ExpectHistogram(s.expected, s.histogram)
+	} else {
+		expectedCorrection, nll = p.Expect(s.expected)
+	}
+	loss := (nll + penalty(weights, s.variance)) / float64(p.SampleCount())
+	observed, observedCorrection := p.Observed()
+	bound := []float64{C}
+	for f := range weights {
+		if s.histogram != nil {
+			start, end := s.offsets[f], s.offsets[f+1]
+			weights[f] += s.delta(weights[f], observed[f], s.histogram[start:end], s.sums[start:end])
+		} else {
+			weights[f] += s.delta(weights[f], observed[f], s.expected[f:f+1], bound)
+		}
+	}
+	if s.gis && observedCorrection > 0 && expectedCorrection > 0 {
+		s.correction += math.Log(observedCorrection/expectedCorrection) / C
+	}
+	p.SetWeights(weights, s.correction)
+	return loss, nil
+}
+
+// lbfgs minimizes the mean negative log posterior with limited memory BFGS
+// and a backtracking line search, every function evaluation is one Expect.
+type lbfgs struct {
+	variance float64
+	s, y     [][]float64
+	loss     float64
+	grad     []float64
+	expected []float64
+}
+
+func (l *lbfgs) Correction() float64 {
+	return 0
+}
+
+// evaluate returns the mean negative log posterior at weights and its
+// gradient (expected - observed + weights / variance) / n.
+func (l *lbfgs) evaluate(p Problem, weights []float64) (float64, []float64) {
+	if l.expected == nil {
+		l.expected = make([]float64, len(weights))
+	}
+	p.SetWeights(weights, 0)
+	_, nll := p.Expect(l.expected)
+	observed, _ := p.Observed()
+	n := float64(p.SampleCount())
+	grad := make([]float64, len(weights))
+	for f := range grad {
+		grad[f] = (l.expected[f] - observed[f]) / n
+		if l.variance > 0 {
+			grad[f] += weights[f] / (l.variance * n)
+		}
+	}
+	return (nll + penalty(weights, l.variance)) / n, grad
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// direction is -H * grad by the two loop recursion.
+func (l *lbfgs) direction() []float64 {
+	d := make([]float64, len(l.grad))
+	for i := range d {
+		d[i] = -l.grad[i]
+	}
+	alpha := make([]float64, len(l.s))
+	for k := len(l.s) - 1; k >= 0; k-- {
+		alpha[k] = dot(l.s[k], d) / dot(l.y[k], l.s[k])
+		for i := range d {
+			d[i] -= alpha[k] * l.y[k][i]
+		}
+	}
+	if k := len(l.s) - 1; k >= 0 {
+		gamma := dot(l.s[k], l.y[k]) / dot(l.y[k], l.y[k])
+		for i := range d {
+			d[i] *= gamma
+		}
+	}
+	for k := range l.s {
+		beta := dot(l.y[k], d) / dot(l.y[k], l.s[k])
+		for i := range d {
+			d[i] += (alpha[k] - beta) * l.s[k][i]
+		}
+	}
+	return d
+}
+
+func (l *lbfgs) Iterate(p Problem, weights []float64) (float64, error) {
+	if l.grad == nil {
+		l.loss, l.grad = l.evaluate(p, weights)
+	}
+	d := l.direction()
+	slope := dot(l.grad, d)
+	if slope >= 0 {
+		// not a descent direction, restart from steepest descent
+		l.s, l.y = nil, nil
+		d = l.direction()
+		slope = dot(l.grad, d)
+	}
+	if slope == 0 {
+		return l.loss, nil
+	}
+	step := 1.0
+	if len(l.s) == 0 {
+		step = math.Min(1, 1/math.Sqrt(-slope))
+	}
+
+	next := make([]float64, len(weights))
+	for try := 0; try < lineSearchTries; try++ {
+		for i := range next {
+			next[i] = weights[i] + step*d[i]
+		}
+		loss, grad := l.evaluate(p, next)
+		if loss <= l.loss+armijo*step*slope {
+			s := make([]float64, len(weights))
+			y := make([]float64, len(weights))
+			for i := range s {
+				s[i] = next[i] - weights[i]
+				y[i] = grad[i] - l.grad[i]
+			}
+			if dot(s, y) > curvatureEpsilon {
+				l.s, l.y = append(l.s, s), append(l.y, y)
+				if len(l.s) > lbfgsMemory {
+					l.s, l.y = l.s[1:], l.y[1:]
+				}
+			}
+			copy(weights, next)
+			l.loss, l.grad = loss, grad
+			return loss, nil
+		}
+		step /= 2
+	}
+	p.SetWeights(weights, 0)
+	return l.loss, errors.New("line search found no decrease")
+}
@@ -0,0 +1,208 @@
+package IIS
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"maxent/dataformat"
+	"metrics"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noisy flips every fifth label of sentiment, so the optimum is finite.
+func noisy(n int, seed int64) []data.Event {
+	events := sentiment(n, seed)
+	for i := 0; i < n; i += 5 {
+		if events[i].Label == "pos" {
+			events[i].Label = "neg"
+		} else {
+			events[i].Label = "pos"
+		}
+	}
+	return events
+}
+
+func trainLoss(t *testing.T, solver string, iter int) float64 {
+	events := noisy(400, 3)
+	c := &Classifier{Solver: solver}
+	if err := c.Train(events, nil, iter); err != nil {
+		t.Fatal(err)
+	}
+	return c.Evaluate(events)[metrics.LogLoss]
+}
+
+func TestSolversReduceLoss(t *testing.T) {
+	start := math.Log(2)
+	losses := map[string]float64{}
+	for _, solver := range []string{SolverIIS, SolverGIS, SolverLBFGS} {
+		losses[solver] = trainLoss(t, solver, 20)
+		if losses[solver] >= start {
+			t.Errorf("%s: loss %v not below %v", solver, losses[solver], start)
+		}
+	}
+	if losses[SolverLBFGS] > losses[SolverIIS] {
+		t.Errorf("lbfgs loss %v above iis %v after the same iterations", losses[SolverLBFGS], losses[SolverIIS])
+	}
+}
+
+func TestSolverConvergesToSameOptimum(t *testing.T) {
+	iis := trainLoss(t, SolverIIS, 2000)
+	lbfgs := trainLoss(t, SolverLBFGS, 200)
+	if math.Abs(iis-lbfgs) > 1e-3 {
+		t.Errorf("iis loss %v, lbfgs loss %v", iis, lbfgs)
+	}
+}
+
+func TestGISCorrectionSaved(t *testing.T) {
+	events := sentiment(200, 5)
+	c := &Classifier{Solver: SolverGIS}
+	if err := c.Train(events, nil, 30); err != nil {
+		t.Fatal(err)
+	}
+	if c.correction == 0 {
+		t.Fatal("GIS left the correction weight at 0")
+	}
+	dir, err := ioutil.TempDir("", "gis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gis.model")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadClassifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events[:20] {
+		want, got := c.Probabilities(event), loaded.Probabilities(event)
+		for li := range want {
+			if math.Abs(want[li]-got[li]) > 1e-12 {
+				t.Fatalf("probabilities %v after load, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestUnknownSolver(t *testing.T) {
+	if _, err := NewSolver("newton", 0); err == nil {
+		t.Error("no error for an unknown solver")
+	}
+}
+
+func TestPriorSolversAgree(t *testing.T) {
+	events := noisy(300, 7)
+	losses := map[string]float64{}
+	for solver, iter := range map[string]int{SolverIIS: 2000, SolverLBFGS: 200} {
+		c := &Classifier{Solver: solver, Variance: 0.5}
+		if err := c.Train(events, nil, iter); err != nil {
+			t.Fatal(err)
+		}
+		losses[solver] = c.Evaluate(events)[metrics.LogLoss]
+	}
+	if math.Abs(losses[SolverIIS]-losses[SolverLBFGS]) > 1e-3 {
+		t.Errorf("iis loss %v, lbfgs loss %v", losses[SolverIIS], losses[SolverLBFGS])
+	}
+}
+
+func TestPriorBoundsWeights(t *testing.T) {
+	// separable, the weights grow without bound unless a prior holds them
+	events := sentiment(300, 7)
+	norm := func(variance float64) float64 {
+		c := &Classifier{Solver: SolverLBFGS, Variance: variance}
+		if err := c.Train(events, nil, 100); err != nil {
+			t.Fatal(err)
+		}
+		w := c.weights.Float64s()
+		return math.Sqrt(dot(w, w))
+	}
+	free, prior := norm(0), norm(1)
+	if prior >= free || prior > 10 {
+		t.Errorf("weight norm %v with prior, %v without", prior, free)
+	}
+}
+
+func TestPriorDelta(t *testing.T) {
+	s := &scaling{variance: 2}
+	for _, c := range [][4]float64{{0.5, 10, 3, 4}, {-1, 0, 5, 784}, {2, 7, 0, 3}, {0, 1000, 1, 784}} {
+		w, observed, expected, C := c[0], c[1], c[2], c[3]
+		d := s.delta(w, observed, []float64{expected}, []float64{C})
+		if g := expected*math.Exp(C*d) + (w+d)/s.variance - observed; math.Abs(g) > 1e-6 {
+			t.Errorf("delta(%v) = %v leaves %v", c, d, g)
+		}
+	}
+}
+
+func TestHistogramDelta(t *testing.T) {
+	expected, sums := []float64{2, 0, 5, 1}, []float64{1, 2, 3.5, 7}
+	for _, variance := range []float64{0, 2} {
+		s := &scaling{variance: variance}
+		for _, observed := range []float64{0.5, 8, 30} {
+			d := s.delta(0.3, observed, expected, sums)
+			g := -observed
+			for m, e := range expected {
+				g += e * math.Exp(sums[m]*d)
+			}
+			if variance > 0 {
+				g += (0.3 + d) / variance
+			}
+			if math.Abs(g) > 1e-6 {
+				t.Errorf("variance %v observed %v: delta %v leaves %v", variance, observed, d, g)
+			}
+		}
+	}
+}
+
+// weighted gives every predicate of noisy a value, f#(x, y) varies a lot.
+func weighted(n int, seed int64) []data.Event {
+	r := rand.New(rand.NewSource(seed))
+	events := noisy(n, seed)
+	for i := range events {
+		events[i].Values = make([]float64, len(events[i].Predicates))
+		for k := range events[i].Values {
+			events[i].Values[k] = 0.2 + 2*r.Float64()
+		}
+	}
+	return events
+}
+
+// boundOnly hides the Histogram methods, IIS falls back to the bound step.
+type boundOnly struct {
+	Problem
+}
+
+func TestExactIISBeatsBoundStep(t *testing.T) {
+	events := weighted(300, 11)
+	iterate := func(p func(c *Classifier) Problem, iter int) float64 {
+		c := &Classifier{}
+		if err := c.prepare(events); err != nil {
+			t.Fatal(err)
+		}
+		solver, _ := NewSolver(SolverIIS, 0)
+		weights := make([]float64, c.FeatureCount())
+		for i := 0; i < iter; i++ {
+			if _, err := solver.Iterate(p(c), weights); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return c.Evaluate(events)[metrics.LogLoss]
+	}
+	exact := iterate(func(c *Classifier) Problem { return c }, 10)
+	bound := iterate(func(c *Classifier) Problem { return boundOnly{c} }, 10)
+	t.Logf("loss after 10 iterations: exact %v, bound %v", exact, bound)
+	if exact >= bound {
+		t.Errorf("exact IIS loss %v, bound step %v after 10 iterations", exact, bound)
+	}
+
+	c := &Classifier{Solver: SolverLBFGS}
+	if err := c.Train(events, nil, 200); err != nil {
+		t.Fatal(err)
+	}
+	optimum := c.Evaluate(events)[metrics.LogLoss]
+	if converged := iterate(func(c *Classifier) Problem { return c }, 1000); math.Abs(converged-optimum) > 1e-3 {
+		t.Errorf("exact IIS loss %v, lbfgs %v", converged, optimum)
+	}
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sentence is one tagged sentence of a CoNLL file, Tokens[i] holds the
+// columns of token i without the tag, the word first, and Tags[i] its tag.
+type Sentence struct {
+	Tokens [][]string
+	Tags   []string
+}
+
+func (s *Sentence) Len() int {
+	return len(s.Tags)
+}
+
+// ReadConll reads blank separated columns, one token per line with the tag
+// in the last column and a blank line after every sentence. `-DOCSTART-`
+// lines are skipped, all tokens of a sentence have the same columns.
+func ReadConll(path string) ([]Sentence, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sentences []Sentence
+	sentence := Sentence{}
+	flush := func() {
+		if sentence.Len() > 0 {
+			sentences = append(sentences, sentence)
+			sentence = Sentence{}
+		}
+	}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		items := strings.Fields(scanner.Text())
+		if len(items) == 0 {
+			flush()
+			continue
+		}
+		if items[0] == "-DOCSTART-" {
+			continue
+		}
+		if len(items) < 2 {
+			return nil, fmt.Errorf("%s:%d: token without tag", path, lineNo)
+		}
+		if sentence.Len() > 0 && len(items)-1 != len(sentence.Tokens[0]) {
+			return nil, fmt.Errorf("%s:%d: %d columns, the sentence started with %d",
+				path, lineNo, len(items), len(sentence.Tokens[0])+1)
+		}
+		sentence.Tokens = append(sentence.Tokens, items[:len(items)-1])
+		sentence.Tags = append(sentence.Tags, items[len(items)-1])
+	}
+	flush()
+	return sentences, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConll(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "conll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "train.conll")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestReadConll(t *testing.T) {
+	path, cleanup := writeConll(t, "-DOCSTART- -X- O\n\nEU NNP B-ORG\nrejects VBZ O\n\n\nPeter NNP B-PER\n")
+	defer cleanup()
+	sentences, err := ReadConll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Sentence{
+		{Tokens: [][]string{{"EU", "NNP"}, {"rejects", "VBZ"}}, Tags: []string{"B-ORG", "O"}},
+		{Tokens: [][]string{{"Peter", "NNP"}}, Tags: []string{"B-PER"}},
+	}
+	if !reflect.DeepEqual(sentences, want) {
+		t.Errorf("read %v, want %v", sentences, want)
+	}
+}
+
+func TestReadConllColumnMismatch(t *testing.T) {
+	path, cleanup := writeConll(t, "EU NNP B-ORG\nrejects O\n")
+	defer cleanup()
+	if _, err := ReadConll(path); err == nil {
+		t.Error("no error for a token with fewer columns")
+	}
+}
@@ -19,6 +19,10 @@ func (f *FuncFeature) IncCount() {
 	f.Count += 1
 }
 
+func NewMnistSample(dataVec []uint8, label int) *MnistSample {
+	return &MnistSample{dataVec: dataVec, label: label}
+}
+
 func (s *MnistSample) GetDataVectorLen() int {
 	return len(s.dataVec)
 }
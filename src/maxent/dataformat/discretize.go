@@ -0,0 +1,186 @@
+package data
+
+import (
+	"fmt"
+	"quantile"
+	"sort"
+)
+
+const (
+	DiscretizeThreshold string = "threshold"
+	DiscretizeWidth     string = "width"
+	DiscretizeQuantile  string = "quantile"
+	DiscretizeRaw       string = "raw"
+
+	// PixelThreshold is the binarization ReadMnistCsv always did
+	PixelThreshold  = 128
+	quantileEpsilon = 0.005
+)
+
+// Discretizer maps raw 0-255 pixels to the values maxent features are built
+// from. Every strategy but raw maps a pixel to the number of its cuts below
+// it, the zero value binarizes at PixelThreshold.
+type Discretizer struct {
+	Strategy string
+	// Bins of width and quantile
+	Bins int `json:",omitempty"`
+	// Cuts are ascending and shared by every pixel, for threshold and width
+	Cuts []float64 `json:",omitempty"`
+	// PixelCuts[j] are the ascending cuts of pixel j, filled by Fit for
+	// quantile
+	PixelCuts [][]float64 `json:",omitempty"`
+}
+
+// Binary maps pixels to 0 and 1, 简化模型，像素点取值映射为0，1
+func Binary() Discretizer {
+	return Threshold(PixelThreshold)
+}
+
+func Threshold(cuts ...float64) Discretizer {
+	sorted := append([]float64(nil), cuts...)
+	sort.Float64s(sorted)
+	return Discretizer{Strategy: DiscretizeThreshold, Cuts: sorted}
+}
+
+// EqualWidth splits 0-255 into bins of the same width.
+func EqualWidth(bins int) Discretizer {
+	cuts := make([]float64, bins-1)
+	for k := range cuts {
+		cuts[k] = 256*float64(k+1)/float64(bins) - 1
+	}
+	return Discretizer{Strategy: DiscretizeWidth, Bins: bins, Cuts: cuts}
+}
+
+func Raw() Discretizer {
+	return Discretizer{Strategy: DiscretizeRaw}
+}
+
+// NewDiscretizer checks a strategy picked at load time, quantile still
+// needs Fit on the training rows.
+func NewDiscretizer(strategy string, bins int, thresholds []float64) (Discretizer, error) {
+	switch strategy {
+	case "", DiscretizeThreshold:
+		if len(thresholds) == 0 {
+			return Binary(), nil
+		}
+		if len(thresholds) > 255 {
+			return Discretizer{}, fmt.Errorf("%d thresholds, at most 255", len(thresholds))
+		}
+		return Threshold(thresholds...), nil
+	case DiscretizeWidth, DiscretizeQuantile:
+		if bins < 2 || bins > 256 {
+			return Discretizer{}, fmt.Errorf("%s discretization needs 2 to 256 bins, not %d", strategy, bins)
+		}
+		if strategy == DiscretizeWidth {
+			return EqualWidth(bins), nil
+		}
+		return Discretizer{Strategy: DiscretizeQuantile, Bins: bins}, nil
+	case DiscretizeRaw:
+		return Raw(), nil
+	}
+	return Discretizer{}, fmt.Errorf("unknown discretization %q", strategy)
+}
+
+// Fit puts the quantile cuts of every pixel position in PixelCuts, so every
+// bin holds about the same number of training pixels. Cuts come from a GK
+// summary of the quantile package, an exact sort when rows are too few for
+// it, and repeated cuts collapse, so mostly blank positions get fewer bins.
+// Other strategies need no fitting.
+func (d *Discretizer) Fit(rows []PixelRow) {
+	if d.Strategy != DiscretizeQuantile || len(rows) == 0 {
+		return
+	}
+	d.PixelCuts = make([][]float64, len(rows[0].Pixels))
+	for j := range d.PixelCuts {
+		query := pixelQuantiles(rows, j)
+		var cuts []float64
+		for k := 1; k < d.Bins; k++ {
+			cut := query(float64(k) / float64(d.Bins))
+			if len(cuts) == 0 || cut > cuts[len(cuts)-1] {
+				cuts = append(cuts, cut)
+			}
+		}
+		d.PixelCuts[j] = cuts
+	}
+}
+
+// pixelQuantiles returns the quantile function of pixel j over rows.
+func pixelQuantiles(rows []PixelRow, j int) func(q float64) float64 {
+	if stream, err := quantile.New(quantileEpsilon, len(rows)); err == nil {
+		for _, row := range rows {
+			stream.Update(float64(pixelAt(row.Pixels, j)))
+		}
+		stream.Finish()
+		return stream.Query
+	}
+	values := make([]float64, len(rows))
+	for i, row := range rows {
+		values[i] = float64(pixelAt(row.Pixels, j))
+	}
+	sort.Float64s(values)
+	return func(q float64) float64 {
+		return values[int(q*float64(len(values)-1))]
+	}
+}
+
+func pixelAt(pixels []uint8, j int) uint8 {
+	if j < len(pixels) {
+		return pixels[j]
+	}
+	return 0
+}
+
+// Levels is the largest number of values a pixel can take.
+func (d *Discretizer) Levels() int {
+	switch d.Strategy {
+	case "":
+		return 2
+	case DiscretizeRaw:
+		return 256
+	}
+	levels := len(d.Cuts) + 1
+	for _, cuts := range d.PixelCuts {
+		if len(cuts)+1 > levels {
+			levels = len(cuts) + 1
+		}
+	}
+	return levels
+}
+
+// Value discretizes pixel j.
+func (d *Discretizer) Value(j int, pixel uint8) uint8 {
+	switch d.Strategy {
+	case "":
+		if pixel > PixelThreshold {
+			return 1
+		}
+		return 0
+	case DiscretizeRaw:
+		return pixel
+	}
+	cuts := d.Cuts
+	if d.PixelCuts != nil {
+		cuts = nil
+		if j < len(d.PixelCuts) {
+			cuts = d.PixelCuts[j]
+		}
+	}
+	// the number of cuts below pixel
+	return uint8(sort.Search(len(cuts), func(i int) bool { return cuts[i] >= float64(pixel) }))
+}
+
+func (d *Discretizer) Apply(pixels []uint8) []uint8 {
+	dataVec := make([]uint8, len(pixels))
+	for j, pixel := range pixels {
+		dataVec[j] = d.Value(j, pixel)
+	}
+	return dataVec
+}
+
+func (d *Discretizer) Samples(rows []PixelRow) []*MnistSample {
+	samples := make([]*MnistSample, len(rows))
+	for i, row := range rows {
+		samples[i] = NewMnistSample(d.Apply(row.Pixels), row.Label)
+	}
+	return samples
+}
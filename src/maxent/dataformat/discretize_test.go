@@ -0,0 +1,106 @@
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscretizerValues(t *testing.T) {
+	pixels := []uint8{0, 64, 65, 128, 129, 255}
+	for _, c := range []struct {
+		d    Discretizer
+		want []uint8
+	}{
+		{Discretizer{}, []uint8{0, 0, 0, 0, 1, 1}},
+		{Binary(), []uint8{0, 0, 0, 0, 1, 1}},
+		{Threshold(192, 64, 128), []uint8{0, 0, 1, 1, 2, 3}},
+		{EqualWidth(4), []uint8{0, 1, 1, 2, 2, 3}},
+		{Raw(), []uint8{0, 64, 65, 128, 129, 255}},
+	} {
+		if got := c.d.Apply(pixels); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v: %v, want %v", c.d, got, c.want)
+		}
+	}
+}
+
+func TestNewDiscretizerChecks(t *testing.T) {
+	for _, c := range []struct {
+		strategy string
+		bins     int
+	}{{DiscretizeWidth, 1}, {DiscretizeQuantile, 300}, {"log", 4}} {
+		if _, err := NewDiscretizer(c.strategy, c.bins, nil); err == nil {
+			t.Errorf("no error for %s with %d bins", c.strategy, c.bins)
+		}
+	}
+}
+
+func TestQuantileFitBalancesBins(t *testing.T) {
+	// pixel 0 is uniform over 0-199, pixel 1 blank but in one row
+	rows := make([]PixelRow, 2000)
+	for i := range rows {
+		rows[i] = PixelRow{Pixels: []uint8{uint8(i % 200), 0}}
+	}
+	rows[0].Pixels[1] = 255
+	for _, n := range []int{len(rows), 20} {
+		d, _ := NewDiscretizer(DiscretizeQuantile, 4, nil)
+		d.Fit(rows[:n])
+		counts := make([]int, d.Levels())
+		for _, row := range rows[:n] {
+			counts[d.Value(0, row.Pixels[0])]++
+		}
+		for v, count := range counts {
+			if count < n/4-n/20 || count > n/4+n/20 {
+				t.Errorf("%d rows: bin %d holds %d, cuts %v", n, v, count, d.PixelCuts[0])
+			}
+		}
+		if len(d.PixelCuts[1]) != 1 {
+			t.Errorf("%d rows: blank pixel cut at %v", n, d.PixelCuts[1])
+		}
+	}
+}
+
+func TestReadMnistStreamsLikePixels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mnist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mnist.csv")
+	lines := "1,0,300,129\n0,-4,128,x\n2,200,7,255\n"
+	if err := ioutil.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, yCount, err := ReadMnistPixels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PixelRow{
+		{Label: 1, Pixels: []uint8{0, 255, 129}},
+		{Label: 0, Pixels: []uint8{0, 128, 0}},
+		{Label: 2, Pixels: []uint8{200, 7, 255}},
+	}
+	if yCount != 3 || !reflect.DeepEqual(rows, want) {
+		t.Fatalf("read %v with %d labels, want %v", rows, yCount, want)
+	}
+
+	for _, d := range []Discretizer{Binary(), Raw(), Threshold(100, 200)} {
+		streamed, count, err := ReadMnistCsvWith(path, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples := d.Samples(rows)
+		if count != yCount || len(streamed) != len(samples) {
+			t.Fatalf("%+v: %d samples with %d labels", d, len(streamed), count)
+		}
+		for i := range samples {
+			if !reflect.DeepEqual(streamed[i].GetDataVec(), samples[i].GetDataVec()) ||
+				streamed[i].GetLabel() != samples[i].GetLabel() {
+				t.Errorf("%+v: line %d streamed %v, from rows %v", d, i, streamed[i].GetDataVec(), samples[i].GetDataVec())
+			}
+		}
+	}
+}
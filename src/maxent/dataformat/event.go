@@ -0,0 +1,81 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Event is one sample of the generic classifier: the outcome and its
+// active contextual predicates such as `word=foo` or `prefix=un`.
+type Event struct {
+	Label      string
+	Predicates []string
+	// Values[i] is the value of Predicates[i], nil means all are 1
+	Values []float64
+}
+
+func (e *Event) Value(i int) float64 {
+	if e.Values == nil {
+		return 1
+	}
+	return e.Values[i]
+}
+
+// ParseEvent reads `label pred pred ...` separated by blanks. A predicate
+// ending in `:number` carries that value, so libsvm lines `1 3:0.5 7:1`
+// are events with predicates `3` and `7`.
+func ParseEvent(line string) (Event, bool) {
+	items := strings.Fields(line)
+	if len(items) == 0 || strings.HasPrefix(items[0], "#") {
+		return Event{}, false
+	}
+	event := Event{Label: items[0], Predicates: make([]string, 0, len(items)-1)}
+	for _, item := range items[1:] {
+		value := 1.0
+		if pos := strings.LastIndex(item, ":"); pos > 0 {
+			if v, err := strconv.ParseFloat(item[pos+1:], 64); err == nil {
+				value, item = v, item[:pos]
+			}
+		}
+		if value != 1 && event.Values == nil {
+			event.Values = make([]float64, len(event.Predicates), len(items)-1)
+			for i := range event.Values {
+				event.Values[i] = 1
+			}
+		}
+		event.Predicates = append(event.Predicates, item)
+		if event.Values != nil {
+			event.Values = append(event.Values, value)
+		}
+	}
+	return event, true
+}
+
+// ReadEvents reads one event per line, blank and `#` lines are skipped.
+func ReadEvents(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if event, ok := ParseEvent(scanner.Text()); ok {
+			for _, v := range event.Values {
+				if v < 0 {
+					return nil, fmt.Errorf("%s:%d: negative predicate value %g", path, lineNo, v)
+				}
+			}
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
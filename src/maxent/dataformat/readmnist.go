@@ -2,42 +2,87 @@ package data
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func ReadMnistCsv(filePath string) (result []*MnistSample, yCount int) {
+// PixelRow is one line of a mnist csv file before discretization, pixels
+// are clamped to 0-255.
+type PixelRow struct {
+	Label  int
+	Pixels []uint8
+}
 
-	yMap := make(map[int]uint8)
-	if file, err := os.Open(filePath); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			items := strings.Split(line, ",")
-			sample := &MnistSample{}
-			if label, err := strconv.Atoi(items[0]); err == nil {
-				sample.label = label
-				yMap[sample.label] = 1
-			}
+func ReadMnistCsv(filePath string) (result []*MnistSample, yCount int, err error) {
+	return ReadMnistCsvWith(filePath, Binary())
+}
+
+// ReadMnistCsvWith reads like ReadMnistCsv with another discretization, e.g.
+// the one saved with a model. d is already fitted, so lines are discretized
+// as they are read and the raw pixels are never kept.
+func ReadMnistCsvWith(filePath string, d Discretizer) (result []*MnistSample, yCount int, err error) {
+	yCount, err = readMnist(filePath, func(row PixelRow) {
+		result = append(result, NewMnistSample(d.Apply(row.Pixels), row.Label))
+	})
+	return
+}
+
+// ReadMnistPixels reads `label,pixel,pixel,...` lines keeping the raw pixels.
+func ReadMnistPixels(filePath string) (rows []PixelRow, yCount int, err error) {
+	yCount, err = readMnist(filePath, func(row PixelRow) {
+		row.Pixels = append([]uint8(nil), row.Pixels...)
+		rows = append(rows, row)
+	})
+	return
+}
 
-			sample.dataVec = make([]uint8, len(items)-1)
-			for i, value := range items[1:] {
-				if di, err := strconv.Atoi(value); err == nil {
-					//sample.dataVec[i] = uint8(di)
+// readMnist calls add with every line of filePath, the pixels are reused
+// for the next line. It returns the number of distinct labels, a file
+// without any line is an error.
+func readMnist(filePath string, add func(row PixelRow)) (int, error) {
+	yMap := make(map[int]uint8)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var pixels []uint8
+	lines := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		items := strings.Split(line, ",")
+		row := PixelRow{}
+		if label, err := strconv.Atoi(items[0]); err == nil {
+			row.Label = label
+			yMap[row.Label] = 1
+		}
 
-					// 简化模型，像素点取值映射为0，1
-					if di > 128 {
-						sample.dataVec[i] = 1
-					} else {
-						sample.dataVec[i] = 0
-					}
+		if cap(pixels) < len(items)-1 {
+			pixels = make([]uint8, len(items)-1)
+		}
+		row.Pixels = pixels[:len(items)-1]
+		for i, value := range items[1:] {
+			row.Pixels[i] = 0
+			if di, err := strconv.Atoi(value); err == nil {
+				if di > 255 {
+					di = 255
+				}
+				if di > 0 {
+					row.Pixels[i] = uint8(di)
 				}
 			}
-			result = append(result, sample)
 		}
+		add(row)
+		lines++
 	}
-	yCount = len(yMap)
-	return
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if lines == 0 {
+		return 0, fmt.Errorf("%s: no samples", filePath)
+	}
+	return len(yMap), nil
 }
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const MetricPrefix string = "kmss_"
+
+type gaugeKey struct {
+	name   string
+	labels string
+}
+
+// Exporter keeps the latest value of every gauge and writes them in the
+// prometheus text format.
+type Exporter struct {
+	lock   sync.Mutex
+	gauges map[gaugeKey]float64
+}
+
+var (
+	exportersLock sync.Mutex
+	exporters     = make(map[string]*Exporter)
+)
+
+func NewExporter() *Exporter {
+	return &Exporter{gauges: make(map[gaugeKey]float64)}
+}
+
+// Listen serves /metrics on addr, trainers in the same process share the
+// exporter of an address.
+func Listen(addr string) (*Exporter, error) {
+	exportersLock.Lock()
+	defer exportersLock.Unlock()
+	if e, ok := exporters[addr]; ok {
+		return e, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	e := NewExporter()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	go http.Serve(listener, mux)
+	exporters[addr] = e
+	return e, nil
+}
+
+// Set updates a gauge, labels are name, value pairs.
+func (e *Exporter) Set(name string, value float64, labels ...string) {
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%s", labels[i], strconv.Quote(labels[i+1])))
+	}
+	e.lock.Lock()
+	e.gauges[gaugeKey{name: MetricPrefix + name, labels: strings.Join(parts, ",")}] = value
+	e.lock.Unlock()
+}
+
+func (e *Exporter) Observe(r Record) {
+	e.Set("epoch", float64(r.Epoch), "model", r.Model)
+	e.Set("step", float64(r.Step), "model", r.Model)
+	e.Set("train_loss", r.TrainLoss, "model", r.Model)
+	e.Set("learning_rate", r.LearningRate, "model", r.Model)
+	e.Set("throughput", r.Throughput, "model", r.Model)
+	e.Set("epoch_seconds", r.Seconds, "model", r.Model)
+	for name, value := range r.Validation {
+		e.Set("validation", value, "model", r.Model, "metric", name)
+	}
+}
+
+// Text renders every gauge in the prometheus text format.
+func (e *Exporter) Text() string {
+	w := &strings.Builder{}
+	e.lock.Lock()
+	keys := make([]gaugeKey, 0, len(e.gauges))
+	for key := range e.gauges {
+		keys = append(keys, key)
+	}
+	values := make(map[gaugeKey]float64, len(e.gauges))
+	for key, value := range e.gauges {
+		values[key] = value
+	}
+	e.lock.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name == keys[j].name {
+			return keys[i].labels < keys[j].labels
+		}
+		return keys[i].name < keys[j].name
+	})
+	for i, key := range keys {
+		if i == 0 || keys[i-1].name != key.name {
+			fmt.Fprintf(w, "# TYPE %s gauge\n", key.name)
+		}
+		value := strconv.FormatFloat(values[key], 'g', -1, 64)
+		if key.labels == "" {
+			fmt.Fprintf(w, "%s %s\n", key.name, value)
+		} else {
+			fmt.Fprintf(w, "%s{%s} %s\n", key.name, key.labels, value)
+		}
+	}
+	return w.String()
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(e.Text()))
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is the state of a training run after one epoch or report.
+type Record struct {
+	Time         time.Time `json:"time"`
+	Model        string    `json:"model"`
+	Epoch        int       `json:"epoch"`
+	Step         int       `json:"step"`
+	TrainLoss    float64   `json:"train_loss"`
+	LearningRate float64   `json:"learning_rate"`
+	// training items per second over the epoch
+	Throughput float64 `json:"throughput"`
+	Seconds    float64 `json:"seconds"`
+	Validation Result  `json:"validation"`
+}
+
+var historyColumns = []string{"time", "model", "epoch", "step", "train_loss", "learning_rate", "throughput", "seconds"}
+
+// History appends records to a csv file, or to a json lines file when the
+// path ends with .jsonl. Runs sharing a file are told apart by the time
+// column. The csv header is written with the first record of a new file,
+// its validation columns are fixed from then on.
+type History struct {
+	file    *os.File
+	jsonl   bool
+	csv     *csv.Writer
+	metrics []string
+}
+
+func OpenHistory(path string) (*History, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	h := &History{jsonl: strings.HasSuffix(path, ".jsonl")}
+	if !h.jsonl {
+		header, err := readHeader(path)
+		if err != nil {
+			return nil, err
+		}
+		if header != nil {
+			// earlier runs fixed the columns, keep appending under them
+			if len(header) < len(historyColumns) {
+				return nil, fmt.Errorf("%s: history header has %d columns", path, len(header))
+			}
+			h.metrics = header[len(historyColumns):]
+		}
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	h.file = file
+	if !h.jsonl {
+		h.csv = csv.NewWriter(file)
+	}
+	return h, nil
+}
+
+// readHeader returns the first csv row of path, nil when the file is
+// missing or empty.
+func readHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header, err := csv.NewReader(file).Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return header, err
+}
+
+func (h *History) Write(r Record) error {
+	if h.jsonl {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = h.file.Write(append(line, '\n'))
+		return err
+	}
+
+	if h.metrics == nil {
+		h.metrics = r.Validation.Names()
+		if err := h.csv.Write(append(append([]string{}, historyColumns...), h.metrics...)); err != nil {
+			return err
+		}
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	row := []string{r.Time.Format(time.RFC3339), r.Model, strconv.Itoa(r.Epoch), strconv.Itoa(r.Step),
+		f(r.TrainLoss), f(r.LearningRate), f(r.Throughput), f(r.Seconds)}
+	for _, name := range h.metrics {
+		if v, ok := r.Validation[name]; ok {
+			row = append(row, f(v))
+		} else {
+			row = append(row, "")
+		}
+	}
+	if err := h.csv.Write(row); err != nil {
+		return err
+	}
+	// flush every record, a long job can be watched while it runs
+	h.csv.Flush()
+	return h.csv.Error()
+}
+
+func (h *History) Close() error {
+	return h.file.Close()
+}
+
+// Recorder sends every record to a history file and to a prometheus
+// exporter, both optional. A nil Recorder records nothing.
+type Recorder struct {
+	model    string
+	history  *History
+	exporter *Exporter
+}
+
+// NewRecorder opens historyPath when set and serves live gauges on
+// metricsAddr when set.
+func NewRecorder(model, historyPath, metricsAddr string) (*Recorder, error) {
+	r := &Recorder{model: model}
+	if historyPath != "" {
+		history, err := OpenHistory(historyPath)
+		if err != nil {
+			return nil, err
+		}
+		r.history = history
+	}
+	if metricsAddr != "" {
+		exporter, err := Listen(metricsAddr)
+		if err != nil {
+			if r.history != nil {
+				r.history.Close()
+			}
+			return nil, fmt.Errorf("metrics endpoint %s: %s", metricsAddr, err.Error())
+		}
+		r.exporter = exporter
+	}
+	return r, nil
+}
+
+func (r *Recorder) Record(record Record) error {
+	if r == nil {
+		return nil
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Model = r.model
+	if r.exporter != nil {
+		r.exporter.Observe(record)
+	}
+	if r.history != nil {
+		return r.history.Write(record)
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	if r == nil || r.history == nil {
+		return nil
+	}
+	return r.history.Close()
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryCSVAndJSONL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	at := time.Date(2019, 3, 22, 15, 4, 5, 0, time.UTC)
+	for _, name := range []string{"run/history.csv", "run/history.jsonl"} {
+		path := filepath.Join(dir, name)
+		recorder, err := NewRecorder("lr", path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Record(Record{Time: at, Epoch: 0, Step: 10, TrainLoss: 0.5, LearningRate: 0.1,
+			Throughput: 100, Seconds: 2, Validation: Result{Accuracy: 0.75, AUCName: 0.8}})
+		// a metric unknown to the csv header is dropped, a missing one is empty
+		recorder.Record(Record{Time: at, Epoch: 1, Step: 20, TrainLoss: 0.25,
+			Validation: Result{Accuracy: 0.875, LogLoss: 0.3}})
+		recorder.Close()
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if strings.HasSuffix(name, ".csv") {
+			want := []string{
+				"time,model,epoch,step,train_loss,learning_rate,throughput,seconds,accuracy,auc",
+				"2019-03-22T15:04:05Z,lr,0,10,0.5,0.1,100,2,0.75,0.8",
+				"2019-03-22T15:04:05Z,lr,1,20,0.25,0,0,0,0.875,",
+			}
+			if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+				t.Errorf("csv\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+			}
+			continue
+		}
+		if len(lines) != 2 {
+			t.Fatalf("%d jsonl lines", len(lines))
+		}
+		record := Record{}
+		if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Model != "lr" || record.Step != 20 || record.Validation[LogLoss] != 0.3 {
+			t.Errorf("unexpected record %+v", record)
+		}
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var recorder *Recorder
+	if err := recorder.Record(Record{Epoch: 1}); err != nil {
+		t.Error(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExporterText(t *testing.T) {
+	e := NewExporter()
+	e.Observe(Record{Model: "softmax", Epoch: 3, TrainLoss: 0.125, Validation: Result{Accuracy: 0.9}})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	for _, line := range []string{
+		"# TYPE kmss_epoch gauge",
+		`kmss_epoch{model="softmax"} 3`,
+		`kmss_train_loss{model="softmax"} 0.125`,
+		`kmss_validation{model="softmax",metric="accuracy"} 0.9`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+}
+
+func TestHistoryAppendsRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	at := time.Date(2019, 3, 22, 15, 4, 5, 0, time.UTC)
+	for _, name := range []string{"history.csv", "history.jsonl"} {
+		path := filepath.Join(dir, name)
+		for run := 0; run < 2; run++ {
+			recorder, err := NewRecorder("lr", path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := Result{Accuracy: 0.5, LogLoss: 0.25}
+			if run == 1 {
+				// the second run keeps the columns of the first, auc is dropped
+				result[AUCName] = 0.9
+			}
+			recorder.Record(Record{Time: at, Epoch: run, Validation: result})
+			recorder.Close()
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if strings.HasSuffix(name, ".jsonl") {
+			if len(lines) != 2 {
+				t.Errorf("%d jsonl lines after two runs", len(lines))
+			}
+			continue
+		}
+		want := []string{
+			"time,model,epoch,step,train_loss,learning_rate,throughput,seconds,accuracy,logloss",
+			"2019-03-22T15:04:05Z,lr,0,0,0,0,0,0,0.5,0.25",
+			"2019-03-22T15:04:05Z,lr,1,0,0,0,0,0,0.5,0.25",
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Errorf("csv\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
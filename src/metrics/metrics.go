@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	Accuracy string = "accuracy"
+	AUCName  string = "auc"
+	LogLoss  string = "logloss"
+
+	epsilon float64 = 1e-15
+)
+
+// Result holds named metric values of one evaluation.
+type Result map[string]float64
+
+func (r Result) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r Result) String() string {
+	var parts []string
+	for _, name := range r.Names() {
+		parts = append(parts, fmt.Sprintf("%s %.06f", name, r[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Fields lists name, value pairs in name order for structured logging.
+func (r Result) Fields() []interface{} {
+	var fields []interface{}
+	for _, name := range r.Names() {
+		fields = append(fields, name, r[name])
+	}
+	return fields
+}
+
+// AUC is the probability that a random positive scores above a random
+// negative, ties count half.
+func AUC(scores []float64, labels []int) float64 {
+	n := len(scores)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] < scores[order[j]] })
+
+	pos, neg := 0.0, 0.0
+	rankSum := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && scores[order[j]] == scores[order[i]] {
+			j++
+		}
+		// average rank (1 based) of the tie group
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if labels[order[k]] == 1 {
+				pos++
+				rankSum += rank
+			} else {
+				neg++
+			}
+		}
+		i = j
+	}
+	if pos == 0 || neg == 0 {
+		return math.NaN()
+	}
+	return (rankSum - pos*(pos+1)/2) / (pos * neg)
+}
+
+// BinaryLogLoss of probabilities for label 1.
+func BinaryLogLoss(probs []float64, labels []int) float64 {
+	if len(probs) == 0 {
+		return math.NaN()
+	}
+	loss := 0.0
+	for i, p := range probs {
+		p = math.Min(math.Max(p, epsilon), 1-epsilon)
+		if labels[i] == 1 {
+			loss -= math.Log(p)
+		} else {
+			loss -= math.Log(1 - p)
+		}
+	}
+	return loss / float64(len(probs))
+}
+
+// NegLog is the log loss of one sample given the probability of its true
+// label, clipped like the other log losses.
+func NegLog(p float64) float64 {
+	return -math.Log(math.Min(math.Max(p, epsilon), 1-epsilon))
+}
+
+// Binary evaluates probabilities for label 1 with threshold 0.5.
+func Binary(probs []float64, labels []int) Result {
+	correct := 0
+	for i, p := range probs {
+		if (p >= 0.5) == (labels[i] == 1) {
+			correct++
+		}
+	}
+	return Result{
+		Accuracy: float64(correct) / float64(len(probs)),
+		AUCName:  AUC(probs, labels),
+		LogLoss:  BinaryLogLoss(probs, labels),
+	}
+}
+
+// Multiclass evaluates the predicted labels and the probability assigned to
+// the true label of every sample.
+func Multiclass(predicted, labels []int, trueProbs []float64) Result {
+	correct := 0
+	loss := 0.0
+	for i, y := range labels {
+		if predicted[i] == y {
+			correct++
+		}
+		loss -= math.Log(math.Max(trueProbs[i], epsilon))
+	}
+	n := float64(len(labels))
+	return Result{
+		Accuracy: float64(correct) / n,
+		LogLoss:  loss / n,
+	}
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAUCWithTies(t *testing.T) {
+	// positive 0.4 beats negative 0.1 and ties negative 0.4, positive 0.8
+	// beats both, (1 + 0.5 + 1 + 1) / 4
+	if auc := AUC([]float64{0.4, 0.1, 0.8, 0.4}, []int{1, 0, 1, 0}); auc != 0.875 {
+		t.Errorf("auc %v, want 0.875", auc)
+	}
+	if auc := AUC([]float64{0.3, 0.3, 0.3}, []int{1, 0, 1}); auc != 0.5 {
+		t.Errorf("all tied auc %v, want 0.5", auc)
+	}
+	if auc := AUC([]float64{0.3, 0.6}, []int{1, 1}); !math.IsNaN(auc) {
+		t.Errorf("auc %v without negatives", auc)
+	}
+}
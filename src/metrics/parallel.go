@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+// ChunkSize is the number of samples one goroutine evaluates at a time.
+// Chunks do not depend on the worker count, so merging them in chunk order
+// gives the same result for any number of workers.
+const ChunkSize int = 256
+
+// Accumulator collects the predictions of one chunk.
+type Accumulator interface {
+	// Merge adds other, which is always of the same type.
+	Merge(other Accumulator)
+	Result() Result
+}
+
+// BinaryAccumulator keeps every probability, auc needs all of them.
+type BinaryAccumulator struct {
+	probs  []float64
+	labels []int
+}
+
+func NewBinaryAccumulator() Accumulator {
+	return &BinaryAccumulator{}
+}
+
+func (a *BinaryAccumulator) Add(prob float64, label int) {
+	a.probs = append(a.probs, prob)
+	a.labels = append(a.labels, label)
+}
+
+func (a *BinaryAccumulator) Merge(other Accumulator) {
+	o := other.(*BinaryAccumulator)
+	a.probs = append(a.probs, o.probs...)
+	a.labels = append(a.labels, o.labels...)
+}
+
+func (a *BinaryAccumulator) Result() Result {
+	return Binary(a.probs, a.labels)
+}
+
+type MulticlassAccumulator struct {
+	count   int
+	correct int
+	loss    float64
+}
+
+func NewMulticlassAccumulator() Accumulator {
+	return &MulticlassAccumulator{}
+}
+
+// Add takes the predicted label and the probability of the true label.
+func (a *MulticlassAccumulator) Add(predicted, label int, trueProb float64) {
+	a.count++
+	if predicted == label {
+		a.correct++
+	}
+	a.loss -= math.Log(math.Max(trueProb, epsilon))
+}
+
+func (a *MulticlassAccumulator) Merge(other Accumulator) {
+	o := other.(*MulticlassAccumulator)
+	a.count += o.count
+	a.correct += o.correct
+	a.loss += o.loss
+}
+
+func (a *MulticlassAccumulator) Result() Result {
+	n := float64(a.count)
+	return Result{
+		Accuracy: float64(a.correct) / n,
+		LogLoss:  a.loss / n,
+	}
+}
+
+// Parallel evaluates samples [0, n) in chunks on workers goroutines, eval
+// adds samples [start, end) to acc. workers <= 0 uses every cpu.
+func Parallel(n, workers int, newAccumulator func() Accumulator,
+	eval func(acc Accumulator, start, end int)) Result {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	chunks := make([]Accumulator, (n+ChunkSize-1)/ChunkSize)
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w < len(chunks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ci := range next {
+				end := (ci + 1) * ChunkSize
+				if end > n {
+					end = n
+				}
+				chunks[ci] = newAccumulator()
+				eval(chunks[ci], ci*ChunkSize, end)
+			}
+		}()
+	}
+	for ci := range chunks {
+		next <- ci
+	}
+	close(next)
+	wg.Wait()
+
+	total := newAccumulator()
+	for _, chunk := range chunks {
+		total.Merge(chunk)
+	}
+	return total.Result()
+}
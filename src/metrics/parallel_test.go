@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestParallelIsDeterministic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	n := 3*ChunkSize + 17
+	probs := make([]float64, n)
+	labels := make([]int, n)
+	predicted := make([]int, n)
+	for i := range probs {
+		probs[i] = r.Float64()
+		labels[i] = r.Intn(2)
+		predicted[i] = r.Intn(2)
+	}
+
+	binary := func(acc Accumulator, start, end int) {
+		for i := start; i < end; i++ {
+			acc.(*BinaryAccumulator).Add(probs[i], labels[i])
+		}
+	}
+	multiclass := func(acc Accumulator, start, end int) {
+		for i := start; i < end; i++ {
+			acc.(*MulticlassAccumulator).Add(predicted[i], labels[i], probs[i])
+		}
+	}
+
+	wantBinary := Binary(probs, labels)
+	wantMulticlass := Multiclass(predicted, labels, probs)
+	first := Parallel(n, 1, NewMulticlassAccumulator, multiclass)
+	for _, workers := range []int{1, 2, 7, 64, 0} {
+		got := Parallel(n, workers, NewBinaryAccumulator, binary)
+		for name, want := range wantBinary {
+			if got[name] != want {
+				t.Errorf("%d workers binary %s %v, serial %v", workers, name, got[name], want)
+			}
+		}
+		got = Parallel(n, workers, NewMulticlassAccumulator, multiclass)
+		for name, want := range wantMulticlass {
+			// chunk sums are merged in chunk order, equal for any worker count
+			if got[name] != first[name] {
+				t.Errorf("%d workers multiclass %s %v, 1 worker %v", workers, name, got[name], first[name])
+			}
+			if math.Abs(got[name]-want) > 1e-12 {
+				t.Errorf("%d workers multiclass %s %v, serial %v", workers, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestParallelEmpty(t *testing.T) {
+	result := Parallel(0, 4, NewMulticlassAccumulator, func(acc Accumulator, start, end int) {
+		t.Error("eval called without samples")
+	})
+	if !math.IsNaN(result[Accuracy]) {
+		t.Errorf("accuracy of nothing %v", result[Accuracy])
+	}
+}
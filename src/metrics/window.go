@@ -0,0 +1,39 @@
+package metrics
+
+// Window keeps the last size (score, label) pairs of a binary model, so
+// metrics follow the recent traffic instead of the whole history.
+type Window struct {
+	scores []float64
+	labels []int
+	next   int
+	full   bool
+}
+
+func NewWindow(size int) *Window {
+	return &Window{scores: make([]float64, size), labels: make([]int, size)}
+}
+
+func (w *Window) Add(score float64, label int) {
+	w.scores[w.next] = score
+	w.labels[w.next] = label
+	w.next++
+	if w.next == len(w.scores) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+func (w *Window) Len() int {
+	if w.full {
+		return len(w.scores)
+	}
+	return w.next
+}
+
+func (w *Window) Result() Result {
+	n := w.Len()
+	if n == 0 {
+		return Result{}
+	}
+	return Binary(w.scores[:n], w.labels[:n])
+}
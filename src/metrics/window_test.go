@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestWindowKeepsLastPairs(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	w := NewWindow(100)
+	if len(w.Result()) != 0 {
+		t.Error("empty window has metrics")
+	}
+	var scores []float64
+	var labels []int
+	for i := 0; i < 250; i++ {
+		score, label := r.Float64(), r.Intn(2)
+		w.Add(score, label)
+		scores, labels = append(scores, score), append(labels, label)
+		if want := i + 1; want <= 100 && w.Len() != want {
+			t.Fatalf("window of %d after %d adds", w.Len(), want)
+		}
+	}
+	got, want := w.Result(), Binary(scores[150:], labels[150:])
+	for _, name := range want.Names() {
+		if diff := got[name] - want[name]; diff > 1e-12 || diff < -1e-12 {
+			t.Errorf("%s %v, want %v over the last 100", name, got[name], want[name])
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package param
+
+import (
+	"encoding/json"
+	"fmt"
+	"vecmath"
+)
+
+const (
+	Float64 string = "float64"
+	Float32 string = "float32"
+)
+
+// Vector stores model weights in float64 or float32. Reads and updates
+// always go through float64, so callers accumulate in float64 and only the
+// stored value is rounded in float32 mode.
+type Vector struct {
+	f64 []float64
+	f32 []float32
+}
+
+func NewVector(n int, precision string) Vector {
+	switch precision {
+	case "", Float64:
+		return Vector{f64: make([]float64, n)}
+	case Float32:
+		return Vector{f32: make([]float32, n)}
+	}
+	panic(fmt.Sprintf("unknown precision %q", precision))
+}
+
+// FromFloat64s wraps values without copying.
+func FromFloat64s(values []float64) Vector {
+	return Vector{f64: values}
+}
+
+func (v *Vector) Precision() string {
+	if v.f32 != nil {
+		return Float32
+	}
+	return Float64
+}
+
+// IsNil is true for a vector that was never allocated.
+func (v *Vector) IsNil() bool {
+	return v.f64 == nil && v.f32 == nil
+}
+
+func (v *Vector) Len() int {
+	if v.f32 != nil {
+		return len(v.f32)
+	}
+	return len(v.f64)
+}
+
+func (v *Vector) At(i int) float64 {
+	if v.f32 != nil {
+		return float64(v.f32[i])
+	}
+	return v.f64[i]
+}
+
+func (v *Vector) Set(i int, x float64) {
+	if v.f32 != nil {
+		v.f32[i] = float32(x)
+	} else {
+		v.f64[i] = x
+	}
+}
+
+func (v *Vector) Add(i int, d float64) {
+	if v.f32 != nil {
+		v.f32[i] = float32(float64(v.f32[i]) + d)
+	} else {
+		v.f64[i] += d
+	}
+}
+
+// Grow extends the vector to n entries, new entries are zero.
+func (v *Vector) Grow(n int) {
+	if n <= v.Len() {
+		return
+	}
+	if v.f32 != nil {
+		values := make([]float32, n)
+		copy(values, v.f32)
+		v.f32 = values
+	} else {
+		values := make([]float64, n)
+		copy(values, v.f64)
+		v.f64 = values
+	}
+}
+
+// Convert changes the storage precision in place.
+func (v *Vector) Convert(precision string) {
+	if precision == "" {
+		precision = Float64
+	}
+	if precision == v.Precision() {
+		return
+	}
+	converted := NewVector(v.Len(), precision)
+	for i := 0; i < v.Len(); i++ {
+		converted.Set(i, v.At(i))
+	}
+	*v = converted
+}
+
+// Float64s returns a float64 copy in float32 mode and the backing slice
+// otherwise.
+func (v *Vector) Float64s() []float64 {
+	if v.f32 == nil {
+		return v.f64
+	}
+	result := make([]float64, len(v.f32))
+	for i, x := range v.f32 {
+		result[i] = float64(x)
+	}
+	return result
+}
+
+// DotDense sums v[j] * x[j] over the shorter of both.
+func (v *Vector) DotDense(x []float64) float64 {
+	if v.f32 != nil {
+		return vecmath.Dot32(v.f32, x)
+	}
+	return vecmath.Dot(v.f64, x)
+}
+
+// AddScaled adds alpha * x[j] to v[j] over the shorter of both.
+func (v *Vector) AddScaled(alpha float64, x []float64) {
+	if v.f32 != nil {
+		vecmath.Axpy32(alpha, x, v.f32)
+	} else {
+		vecmath.Axpy(alpha, x, v.f64)
+	}
+}
+
+// DotSparse sums v[index[k]] * values[k], indexes beyond the vector are
+// ignored.
+func (v *Vector) DotSparse(index []int, values []float64) float64 {
+	sum := 0.0
+	n := v.Len()
+	for k, j := range index {
+		if j < n {
+			sum += v.At(j) * values[k]
+		}
+	}
+	return sum
+}
+
+// MarshalJSON writes a plain number array in both modes, float32 values
+// are formatted with float32 precision which also shortens the file.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	if v.f32 != nil {
+		return json.Marshal(v.f32)
+	}
+	return json.Marshal(v.f64)
+}
+
+// UnmarshalJSON always loads float64, owners call Convert with the
+// precision they saved next to the vector.
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*v = Vector{f64: values}
+	return nil
+}
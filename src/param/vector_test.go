@@ -0,0 +1,68 @@
+package param
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestFloat32RoundsStoredValues(t *testing.T) {
+	v := NewVector(3, Float32)
+	v.Set(0, 0.1)
+	v.Add(0, 0.2)
+	if v.Precision() != Float32 {
+		t.Fatalf("precision %s", v.Precision())
+	}
+	if got, want := v.At(0), float64(float32(float64(float32(0.1))+0.2)); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if math.Abs(v.At(0)-0.3) > 1e-7 {
+		t.Errorf("float32 value %v too far from 0.3", v.At(0))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, precision := range []string{Float64, Float32} {
+		v := NewVector(4, precision)
+		for i := 0; i < v.Len(); i++ {
+			v.Set(i, float64(i)/3)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var loaded Vector
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			t.Fatal(err)
+		}
+		loaded.Convert(precision)
+		if loaded.Precision() != precision || loaded.Len() != v.Len() {
+			t.Fatalf("%s: loaded %s vector of %d", precision, loaded.Precision(), loaded.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			if loaded.At(i) != v.At(i) {
+				t.Errorf("%s: [%d] %v != %v", precision, i, loaded.At(i), v.At(i))
+			}
+		}
+	}
+}
+
+func TestGrowAndDot(t *testing.T) {
+	v := NewVector(2, Float32)
+	v.Set(1, 2)
+	v.Grow(5)
+	v.Set(4, 3)
+	if v.Len() != 5 || v.At(1) != 2 {
+		t.Fatalf("grow lost values: len %d, [1] %v", v.Len(), v.At(1))
+	}
+	if got := v.DotDense([]float64{1, 1, 1}); got != 2 {
+		t.Errorf("dense dot %v", got)
+	}
+	if got := v.DotSparse([]int{1, 4, 9}, []float64{1, 2, 5}); got != 8 {
+		t.Errorf("sparse dot %v", got)
+	}
+	v.Convert(Float64)
+	if v.Precision() != Float64 || v.At(4) != 3 {
+		t.Errorf("convert: %s %v", v.Precision(), v.At(4))
+	}
+}
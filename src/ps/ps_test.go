@@ -0,0 +1,174 @@
+package ps
+
+import (
+	"LR"
+	"config"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"metrics"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// separable data: label is 1 when feature 0 outweighs feature 1
+func testItems(n int) []LR.SparseTrainItem {
+	r := rand.New(rand.NewSource(1))
+	items := make([]LR.SparseTrainItem, n)
+	for i := range items {
+		a, b := r.Float64(), r.Float64()
+		label := 0
+		if a > b {
+			label = 1
+		}
+		items[i] = LR.SparseTrainItem{
+			Label:    label,
+			Features: map[int]float64{0: a, 1: b, 2 + r.Intn(8): 1},
+		}
+	}
+	return items
+}
+
+func runLocal(t *testing.T, addr string, staleness int) {
+	conf := config.TrainConf{FeatureLen: 10, LearningRate: 1, Shards: 3, Staleness: staleness}
+	workers := 4
+	server := NewServer(conf, workers)
+	listener, err := Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go server.Serve(listener)
+
+	items := testItems(4000)
+	historyPath := filepath.Join(t.TempDir(), "history.csv")
+	connect := listener.Addr().String()
+	if listener.Addr().Network() == "unix" {
+		connect = "unix://" + connect
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			worker, err := Dial(connect)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer worker.Close()
+			if shard == 0 {
+				if worker.Recorder, err = metrics.NewRecorder("ps", historyPath, ""); err != nil {
+					t.Error(err)
+					return
+				}
+				defer worker.Recorder.Close()
+			}
+			if err := worker.Train(Shard(items, shard, workers), 5, 20); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := server.Wait(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ioutil.ReadFile(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a header and one row per iteration
+	if lines := strings.Split(strings.TrimSpace(string(history)), "\n"); len(lines) != 6 {
+		t.Errorf("%d history lines\n%s", len(lines), history)
+	}
+
+	if staleness >= 0 && server.MaxGap() > staleness {
+		t.Errorf("clock gap %d exceeds staleness %d", server.MaxGap(), staleness)
+	}
+	model := server.Model()
+	if model.Weights.At(0) <= 0 || model.Weights.At(1) >= 0 {
+		t.Errorf("unexpected weights %v %v", model.Weights.At(0), model.Weights.At(1))
+	}
+	if ac := model.Evaluate(items)["accuracy"]; ac < 0.9 {
+		t.Errorf("accuracy %f too low", ac)
+	}
+}
+
+func TestParameterServerTCP(t *testing.T) {
+	runLocal(t, "127.0.0.1:0", 1)
+}
+
+func TestParameterServerUnix(t *testing.T) {
+	runLocal(t, "unix://"+filepath.Join(t.TempDir(), "ps.sock"), 0)
+}
+
+func TestParameterServerAsync(t *testing.T) {
+	runLocal(t, "127.0.0.1:0", -1)
+}
+
+func listenLocal(t *testing.T, server *Server) string {
+	listener, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+	return listener.Addr().String()
+}
+
+func TestTrainRejectsZeroBatch(t *testing.T) {
+	server := NewServer(config.TrainConf{FeatureLen: 10, LearningRate: 1}, 1)
+	worker, err := Dial(listenLocal(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer worker.Close()
+	if err := worker.Train(testItems(100), 1, 0); err == nil {
+		t.Error("batch size 0 accepted")
+	}
+	// the failed worker still counts as done
+	if err := server.Wait(time.Minute); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWaitTimesOutOnDeadWorker(t *testing.T) {
+	server := NewServer(config.TrainConf{FeatureLen: 10, LearningRate: 1}, 2)
+	addr := listenLocal(t, server)
+	dead, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// registered, then gone without a clock or Done
+	dead.Close()
+
+	alive, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// blocks on its second pull, waiting for the dead worker's clock
+	go alive.Train(testItems(100), 1, 10)
+
+	start := time.Now()
+	if err := server.Wait(200 * time.Millisecond); err == nil {
+		t.Error("wait returned without every worker done")
+	} else {
+		t.Log(err)
+	}
+	if cost := time.Now().Sub(start); cost > 5*time.Second {
+		t.Errorf("timeout took %s", cost)
+	}
+}
+
+func TestFailStopsWait(t *testing.T) {
+	server := NewServer(config.TrainConf{FeatureLen: 10}, 2)
+	server.Fail(errors.New("worker 1 exit"))
+	server.Fail(errors.New("worker 0 exit"))
+	if err := server.Wait(0); err == nil || err.Error() != "worker 1 exit" {
+		t.Errorf("wait returned %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package ps
+
+import (
+	"LR"
+	"config"
+	"fmt"
+	"net"
+	"net/rpc"
+	"param"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ServiceName   string = "PS"
+	DefaultShards int    = 16
+)
+
+type PullArgs struct {
+	Worker int
+	Clock  int
+	Keys   []int
+}
+
+type PullReply struct {
+	Values []float64
+	Bias   float64
+}
+
+type PushArgs struct {
+	Worker int
+	Keys   []int
+	Grads  []float64
+	Bias   float64
+}
+
+type ClockArgs struct {
+	Worker int
+	Clock  int
+}
+
+type RegisterReply struct {
+	Worker     int
+	FeatureLen int
+}
+
+type Empty struct{}
+
+// shard owns every weight whose index % shards equals its id.
+type shard struct {
+	lock    sync.Mutex
+	weights param.Vector
+}
+
+// Server keeps the LR weights and applies pushed gradients. Workers follow
+// stale synchronous parallel: a worker at clock c may only pull when the
+// slowest unfinished worker has reached c - staleness. Staleness 0 is bulk
+// synchronous, a negative staleness never blocks.
+type Server struct {
+	featureLen   int
+	learningRate float64
+	precision    string
+	shards       []*shard
+
+	biasLock sync.Mutex
+	bias     float64
+
+	clockLock    sync.Mutex
+	clockChanged *sync.Cond
+	staleness    int
+	expected     int
+	nextWorker   int
+	clocks       map[int]int
+	done         map[int]bool
+	maxGap       int
+	allDone      chan struct{}
+	// last register, clock or done call, Wait gives up when it gets too old
+	active  time.Time
+	failed  chan struct{}
+	failure error
+}
+
+func NewServer(conf config.TrainConf, workers int) *Server {
+	shards := conf.Shards
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	s := &Server{
+		featureLen:   conf.FeatureLen,
+		learningRate: conf.LearningRate,
+		precision:    conf.Precision,
+		shards:       make([]*shard, shards),
+		staleness:    conf.Staleness,
+		expected:     workers,
+		clocks:       make(map[int]int),
+		done:         make(map[int]bool),
+		allDone:      make(chan struct{}),
+		active:       time.Now(),
+		failed:       make(chan struct{}),
+	}
+	s.clockChanged = sync.NewCond(&s.clockLock)
+	for i := range s.shards {
+		s.shards[i] = &shard{weights: param.NewVector((conf.FeatureLen+shards-1)/shards, conf.Precision)}
+	}
+	return s
+}
+
+// Listen accepts `unix:///path/to.sock` or a tcp `host:port`.
+func Listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, "unix://") {
+		return net.Listen("unix", addr[len("unix://"):])
+	}
+	return net.Listen("tcp", addr)
+}
+
+func dial(addr string) (*rpc.Client, error) {
+	if strings.HasPrefix(addr, "unix://") {
+		return rpc.Dial("unix", addr[len("unix://"):])
+	}
+	return rpc.Dial("tcp", addr)
+}
+
+// Serve handles connections until the listener is closed.
+func (s *Server) Serve(listener net.Listener) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, &service{s}); err != nil {
+		panic(err.Error())
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// Wait blocks until every expected worker called Done. It fails when Fail
+// is called, or when no worker registered or advanced its clock for
+// timeout, e.g. because a worker died and the others wait for its clock.
+// A timeout <= 0 waits forever.
+func (s *Server) Wait(timeout time.Duration) error {
+	var idle <-chan time.Time
+	if timeout > 0 {
+		ticker := time.NewTicker(timeout / 10)
+		defer ticker.Stop()
+		idle = ticker.C
+	}
+	for {
+		select {
+		case <-s.allDone:
+			return nil
+		case <-s.failed:
+			return s.failure
+		case now := <-idle:
+			s.clockLock.Lock()
+			since, done := now.Sub(s.active), len(s.done)
+			s.clockLock.Unlock()
+			if since > timeout {
+				return fmt.Errorf("no worker progress for %s, %d of %d workers done",
+					since.Round(time.Millisecond), done, s.expected)
+			}
+		}
+	}
+}
+
+// Fail makes Wait return err, e.g. when the launcher sees a worker process
+// exit before it is done. Only the first failure is kept.
+func (s *Server) Fail(err error) {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	if s.failure != nil {
+		return
+	}
+	s.failure = err
+	close(s.failed)
+}
+
+// MaxGap is the largest clock distance seen between a pulling worker and
+// the slowest worker, it never exceeds the staleness bound.
+func (s *Server) MaxGap() int {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	return s.maxGap
+}
+
+func (s *Server) Model() *LR.LogisticRegression {
+	model := &LR.LogisticRegression{
+		Weights:    param.NewVector(s.featureLen, s.precision),
+		FeatureLen: s.featureLen,
+		Precision:  s.precision,
+	}
+	n := len(s.shards)
+	for si, sh := range s.shards {
+		sh.lock.Lock()
+		for i := 0; i < sh.weights.Len(); i++ {
+			if k := i*n + si; k < s.featureLen {
+				model.Weights.Set(k, sh.weights.At(i))
+			}
+		}
+		sh.lock.Unlock()
+	}
+	s.biasLock.Lock()
+	model.Bias = s.bias
+	s.biasLock.Unlock()
+	return model
+}
+
+// minClock must be called with clockLock held.
+func (s *Server) minClock() (int, bool) {
+	min, found := 0, false
+	for worker, clock := range s.clocks {
+		if s.done[worker] {
+			continue
+		}
+		if !found || clock < min {
+			min, found = clock, true
+		}
+	}
+	return min, found
+}
+
+func (s *Server) waitClock(clock int) {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	for {
+		// nobody runs ahead before every worker has registered
+		if s.staleness >= 0 && len(s.clocks) < s.expected {
+			s.clockChanged.Wait()
+			continue
+		}
+		min, found := s.minClock()
+		if !found || s.staleness < 0 || clock-min <= s.staleness {
+			if found && clock-min > s.maxGap {
+				s.maxGap = clock - min
+			}
+			return
+		}
+		s.clockChanged.Wait()
+	}
+}
+
+// groupKeys splits keys by shard, keeping the position of every key.
+func (s *Server) groupKeys(keys []int) [][]int {
+	groups := make([][]int, len(s.shards))
+	for pos, k := range keys {
+		si := k % len(s.shards)
+		groups[si] = append(groups[si], pos)
+	}
+	return groups
+}
+
+func (s *Server) pull(args *PullArgs, reply *PullReply) error {
+	s.waitClock(args.Clock)
+	reply.Values = make([]float64, len(args.Keys))
+	n := len(s.shards)
+	for si, positions := range s.groupKeys(args.Keys) {
+		if len(positions) == 0 {
+			continue
+		}
+		sh := s.shards[si]
+		sh.lock.Lock()
+		for _, pos := range positions {
+			k := args.Keys[pos]
+			if k < 0 || k >= s.featureLen {
+				sh.lock.Unlock()
+				return fmt.Errorf("feature index %d out of range %d", k, s.featureLen)
+			}
+			reply.Values[pos] = sh.weights.At(k / n)
+		}
+		sh.lock.Unlock()
+	}
+	s.biasLock.Lock()
+	reply.Bias = s.bias
+	s.biasLock.Unlock()
+	return nil
+}
+
+func (s *Server) push(args *PushArgs) error {
+	if len(args.Keys) != len(args.Grads) {
+		return fmt.Errorf("%d keys but %d gradients", len(args.Keys), len(args.Grads))
+	}
+	n := len(s.shards)
+	for si, positions := range s.groupKeys(args.Keys) {
+		if len(positions) == 0 {
+			continue
+		}
+		sh := s.shards[si]
+		sh.lock.Lock()
+		for _, pos := range positions {
+			if k := args.Keys[pos]; k >= 0 && k < s.featureLen {
+				sh.weights.Add(k/n, s.learningRate*args.Grads[pos])
+			}
+		}
+		sh.lock.Unlock()
+	}
+	s.biasLock.Lock()
+	s.bias += s.learningRate * args.Bias
+	s.biasLock.Unlock()
+	return nil
+}
+
+func (s *Server) register() RegisterReply {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	worker := s.nextWorker
+	s.nextWorker++
+	s.clocks[worker] = 0
+	s.active = time.Now()
+	s.clockChanged.Broadcast()
+	return RegisterReply{Worker: worker, FeatureLen: s.featureLen}
+}
+
+func (s *Server) clock(args *ClockArgs) {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	if args.Clock > s.clocks[args.Worker] {
+		s.clocks[args.Worker] = args.Clock
+	}
+	s.active = time.Now()
+	s.clockChanged.Broadcast()
+}
+
+func (s *Server) finish(worker int) {
+	s.clockLock.Lock()
+	defer s.clockLock.Unlock()
+	if s.done[worker] {
+		return
+	}
+	s.done[worker] = true
+	s.active = time.Now()
+	s.clockChanged.Broadcast()
+	if len(s.done) == s.expected {
+		close(s.allDone)
+	}
+}
+
+// service is the rpc facing side of Server.
+type service struct {
+	s *Server
+}
+
+func (ps *service) Register(args *Empty, reply *RegisterReply) error {
+	*reply = ps.s.register()
+	return nil
+}
+
+func (ps *service) Pull(args *PullArgs, reply *PullReply) error {
+	return ps.s.pull(args, reply)
+}
+
+func (ps *service) Push(args *PushArgs, reply *Empty) error {
+	return ps.s.push(args)
+}
+
+func (ps *service) Clock(args *ClockArgs, reply *Empty) error {
+	ps.s.clock(args)
+	return nil
+}
+
+func (ps *service) Done(args *ClockArgs, reply *Empty) error {
+	ps.s.finish(args.Worker)
+	return nil
+}
@@ -0,0 +1,158 @@
+package ps
+
+import (
+	"LR"
+	"fmt"
+	"logging"
+	"math"
+	"metrics"
+	"net/rpc"
+	"sort"
+	"time"
+)
+
+// Worker trains on its own part of the data, it only pulls the weights of
+// features present in the current mini-batch and pushes sparse gradients.
+type Worker struct {
+	// Recorder gets the train loss of every iteration when set
+	Recorder *metrics.Recorder
+
+	client     *rpc.Client
+	id         int
+	featureLen int
+	clock      int
+}
+
+func Dial(addr string) (*Worker, error) {
+	client, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	reply := RegisterReply{}
+	if err := client.Call(ServiceName+".Register", &Empty{}, &reply); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Worker{client: client, id: reply.Worker, featureLen: reply.FeatureLen}, nil
+}
+
+func (w *Worker) ID() int {
+	return w.id
+}
+
+func (w *Worker) Close() error {
+	return w.client.Close()
+}
+
+func sigmoid(z float64) float64 {
+	return 1.0 / (1 + math.Exp(-z))
+}
+
+// step runs one mini-batch: pull, compute the log likelihood gradient,
+// push and advance the clock. It returns the log loss summed over batch
+// under the pulled weights.
+func (w *Worker) step(batch []LR.SparseTrainItem) (float64, error) {
+	keySet := make(map[int]int)
+	for _, item := range batch {
+		for k := range item.Features {
+			keySet[k] = 0
+		}
+	}
+	keys := make([]int, 0, len(keySet))
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for pos, k := range keys {
+		keySet[k] = pos
+	}
+
+	pulled := PullReply{}
+	if err := w.client.Call(ServiceName+".Pull",
+		&PullArgs{Worker: w.id, Clock: w.clock, Keys: keys}, &pulled); err != nil {
+		return 0, err
+	}
+
+	grads := make([]float64, len(keys))
+	biasGrad, loss := 0.0, 0.0
+	for _, item := range batch {
+		z := pulled.Bias
+		for k, v := range item.Features {
+			z += pulled.Values[keySet[k]] * v
+		}
+		p := sigmoid(z)
+		if item.Label == 1 {
+			loss += metrics.NegLog(p)
+		} else {
+			loss += metrics.NegLog(1 - p)
+		}
+		residual := float64(item.Label) - p
+		biasGrad += residual
+		for k, v := range item.Features {
+			grads[keySet[k]] += residual * v
+		}
+	}
+	n := float64(len(batch))
+	for i := range grads {
+		grads[i] /= n
+	}
+
+	if err := w.client.Call(ServiceName+".Push",
+		&PushArgs{Worker: w.id, Keys: keys, Grads: grads, Bias: biasGrad / n}, &Empty{}); err != nil {
+		return 0, err
+	}
+	w.clock++
+	return loss, w.client.Call(ServiceName+".Clock", &ClockArgs{Worker: w.id, Clock: w.clock}, &Empty{})
+}
+
+// Train runs iter epochs of mini-batches over items and tells the server
+// this worker is done, even when training fails. Every epoch is recorded
+// with the worker clock as step.
+func (w *Worker) Train(items []LR.SparseTrainItem, iter, batchSize int) error {
+	defer w.client.Call(ServiceName+".Done", &ClockArgs{Worker: w.id, Clock: w.clock}, &Empty{})
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size %d, set onebatch", batchSize)
+	}
+	for _, item := range items {
+		for k := range item.Features {
+			if k >= w.featureLen {
+				return fmt.Errorf("feature index %d out of range %d", k, w.featureLen)
+			}
+		}
+	}
+
+	for it := 0; it < iter; it++ {
+		start := time.Now()
+		trainLoss := 0.0
+		for bi := 0; bi < len(items); bi += batchSize {
+			end := bi + batchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			loss, err := w.step(items[bi:end])
+			if err != nil {
+				return err
+			}
+			trainLoss += loss
+		}
+		if len(items) > 0 {
+			trainLoss /= float64(len(items))
+		}
+		cost := time.Now().Sub(start)
+		logging.Info("iter done", "worker", w.id, "iter", it, "clock", w.clock,
+			"train_loss", trainLoss, "cost", cost)
+		w.Recorder.Record(metrics.Record{Epoch: it, Step: w.clock, TrainLoss: trainLoss,
+			Throughput: float64(len(items)) / cost.Seconds(), Seconds: cost.Seconds()})
+	}
+	return nil
+}
+
+// Shard keeps every workers-th item starting at index, so workers reading
+// the same file train on disjoint parts of it.
+func Shard(items []LR.SparseTrainItem, index, workers int) []LR.SparseTrainItem {
+	var result []LR.SparseTrainItem
+	for i := index; i < len(items); i += workers {
+		result = append(result, items[i])
+	}
+	return result
+}
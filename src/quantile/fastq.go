@@ -77,7 +77,8 @@ type Stream struct {
 func New(epsilon float64, n int) (*Stream, error) {
 	epsN := epsilon * float64(n)
 	b := int(math.Floor(math.Log(epsN) / epsilon))
-	if b < 0 {
+	// a block holds at least one value
+	if b < 1 {
 		return nil, errors.New("epsilon too accurate for n")
 	}
 	return &Stream{summary: make([]gksummary, 1, 1), epsilon: epsilon, n: n, b: b}, nil
@@ -117,8 +118,7 @@ func (s *Stream) Update(e float64) {
 			   -------------------------------------- */
 
 			s.summary[k] = sc // Store it
-			s.Dump()
-			return // Done
+			return            // Done
 		}
 
 		/* --------------------------------------
@@ -135,7 +135,6 @@ func (s *Stream) Update(e float64) {
 
 	// fell off the end of our loop -- no more s.summary entries
 	s.summary = append(s.summary, sc)
-	s.Dump()
 
 }
 
@@ -0,0 +1,217 @@
+package tuning
+
+import (
+	"config"
+	"encoding/json"
+	"fmt"
+	"logging"
+	"math"
+	"math/rand"
+	"metrics"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Objective trains with conf for iter more epochs and returns validation
+// metrics with the trained model. model is nil for a new trial, successive
+// halving passes the model a promoted trial returned in the previous rung.
+type Objective func(conf config.TrainConf, iter int, model interface{}) (metrics.Result, interface{})
+
+type Trial struct {
+	ID   int `json:"id"`
+	Rung int `json:"rung"`
+	Iter int `json:"iter"`
+	// Resumed epochs were trained in the previous rung
+	Resumed int                    `json:"resumed,omitempty"`
+	Params  map[string]interface{} `json:"params"`
+	Metrics metrics.Result         `json:"metrics"`
+	Seconds float64                `json:"seconds"`
+	Error   string                 `json:"error,omitempty"`
+	conf    config.TrainConf
+	model   interface{}
+}
+
+type Search struct {
+	spec      *Spec
+	base      config.TrainConf
+	objective Objective
+	r         *rand.Rand
+
+	nextID  int
+	lock    sync.Mutex
+	results *os.File
+}
+
+func NewSearch(spec *Spec, base config.TrainConf, objective Objective) *Search {
+	seed := spec.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Search{spec: spec, base: base, objective: objective, r: rand.New(rand.NewSource(seed))}
+}
+
+// lowerIsBetter is true for loss style metrics.
+func lowerIsBetter(metric string) bool {
+	return metric == metrics.LogLoss
+}
+
+func (s *Search) better(a, b *Trial) bool {
+	va, oka := a.Metrics[s.spec.Metric]
+	vb, okb := b.Metrics[s.spec.Metric]
+	if !oka || math.IsNaN(va) || a.Error != "" {
+		return false
+	}
+	if !okb || math.IsNaN(vb) || b.Error != "" {
+		return true
+	}
+	if lowerIsBetter(s.spec.Metric) {
+		return va < vb
+	}
+	return va > vb
+}
+
+// Run executes the search and returns the best trial of the final round.
+func (s *Search) Run() (*Trial, error) {
+	if s.spec.Results != "" {
+		file, err := os.Create(s.spec.Results)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		s.results = file
+	}
+
+	var trials []*Trial
+	switch s.spec.Method {
+	case Grid:
+		trials = s.runRung(s.newTrials(s.spec.grid(), s.spec.Iter), 0)
+	case Random:
+		trials = s.runRung(s.newTrials(s.spec.sample(s.r, s.spec.Trials), s.spec.Iter), 0)
+	case Halving:
+		trials = s.halving()
+	default:
+		return nil, fmt.Errorf("unknown search method %q", s.spec.Method)
+	}
+
+	var best *Trial
+	for _, trial := range trials {
+		if best == nil || s.better(trial, best) {
+			best = trial
+		}
+	}
+	if best == nil || best.Error != "" {
+		return nil, fmt.Errorf("no successful trial")
+	}
+	return best, nil
+}
+
+func (s *Search) newTrials(points []map[string]interface{}, iter int) []*Trial {
+	trials := make([]*Trial, len(points))
+	for i, params := range points {
+		trials[i] = &Trial{Iter: iter, Params: params}
+	}
+	return trials
+}
+
+// halving starts every sampled config with MinIter epochs, then keeps the
+// best 1/eta of them and multiplies the epochs by eta until Iter is reached.
+// Promoted trials continue training their model of the previous rung.
+func (s *Search) halving() []*Trial {
+	budget := s.spec.MinIter
+	if budget > s.spec.Iter {
+		budget = s.spec.Iter
+	}
+	trials := s.newTrials(s.spec.sample(s.r, s.spec.Trials), budget)
+	for rung := 0; ; rung++ {
+		trials = s.runRung(trials, rung)
+		if len(trials) <= 1 || budget >= s.spec.Iter {
+			return trials
+		}
+
+		sort.SliceStable(trials, func(i, j int) bool { return s.better(trials[i], trials[j]) })
+		keep := (len(trials) + s.spec.Eta - 1) / s.spec.Eta
+		budget *= s.spec.Eta
+		if budget > s.spec.Iter {
+			budget = s.spec.Iter
+		}
+		promoted := make([]*Trial, keep)
+		for i, trial := range trials[:keep] {
+			promoted[i] = &Trial{Iter: budget, Resumed: trial.Iter, Params: trial.Params, model: trial.model}
+		}
+		for _, trial := range trials {
+			trial.model = nil
+		}
+		logging.Info("rung done", "rung", rung, "promoted", keep, "iter", budget)
+		trials = promoted
+	}
+}
+
+func (s *Search) runRung(trials []*Trial, rung int) []*Trial {
+	for _, trial := range trials {
+		trial.ID, trial.Rung = s.nextID, rung
+		s.nextID++
+	}
+
+	jobs := make(chan *Trial)
+	wg := sync.WaitGroup{}
+	for w := 0; w < s.spec.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trial := range jobs {
+				s.runTrial(trial)
+			}
+		}()
+	}
+	for _, trial := range trials {
+		jobs <- trial
+	}
+	close(jobs)
+	wg.Wait()
+	return trials
+}
+
+func (s *Search) runTrial(trial *Trial) {
+	start := time.Now()
+	conf, err := s.base.Override(trial.Params)
+	if err == nil {
+		trial.conf = conf
+		trial.Metrics, trial.model = s.objective(conf, trial.Iter-trial.Resumed, trial.model)
+	} else {
+		trial.Error = err.Error()
+	}
+	trial.Seconds = time.Now().Sub(start).Seconds()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry := logging.With(trial.Metrics.Fields()...)
+	if trial.Error != "" {
+		entry.Error("trial failed", "trial", trial.ID, "rung", trial.Rung, "params", trial.Params, "err", trial.Error)
+	} else {
+		entry.Info("trial done", "trial", trial.ID, "rung", trial.Rung, "iter", trial.Iter, "params", trial.Params)
+	}
+	if s.results != nil {
+		if line, err := json.Marshal(trial); err == nil {
+			s.results.Write(append(line, '\n'))
+		} else {
+			logging.Error("write trial", "err", err)
+		}
+	}
+}
+
+// SaveBest writes the whole config with the searched section replaced by
+// the best trial's conf, ready to be passed with --conf=.
+func SaveBest(path, model string, best *Trial) error {
+	conf := config.GetConfig()
+	switch model {
+	case "lr":
+		conf.LRConf = best.conf
+	case "softmax":
+		conf.SoftmaxConf = best.conf
+	default:
+		return fmt.Errorf("unknown model %q", model)
+	}
+	return conf.SaveConfig(path)
+}
@@ -0,0 +1,150 @@
+package tuning
+
+import (
+	"config"
+	"io/ioutil"
+	"math/rand"
+	"metrics"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestGridExpandsProduct(t *testing.T) {
+	spec := &Spec{Params: map[string]Param{
+		"learningRate": {Values: []interface{}{0.1, 0.01}},
+		"onebatch":     {Values: []interface{}{10, 100, 1000}},
+	}}
+	points := spec.grid()
+	if len(points) != 6 {
+		t.Fatalf("%d grid points", len(points))
+	}
+	seen := make(map[[2]interface{}]bool)
+	for _, point := range points {
+		seen[[2]interface{}{point["learningRate"], point["onebatch"]}] = true
+	}
+	for _, rate := range spec.Params["learningRate"].Values {
+		for _, batch := range spec.Params["onebatch"].Values {
+			if !seen[[2]interface{}{rate, batch}] {
+				t.Errorf("grid misses %v %v", rate, batch)
+			}
+		}
+	}
+}
+
+func TestSampleStaysInRange(t *testing.T) {
+	spec := &Spec{Params: map[string]Param{
+		"learningRate": {Min: 1e-4, Max: 1, Log: true},
+		"onebatch":     {Min: 10, Max: 20, Int: true},
+		"normal":       {Values: []interface{}{"l1", "l2"}},
+	}}
+	var small int
+	for _, point := range spec.sample(rand.New(rand.NewSource(1)), 1000) {
+		rate := point["learningRate"].(float64)
+		if rate < 1e-4 || rate > 1 {
+			t.Fatalf("learning rate %v", rate)
+		}
+		if rate < 1e-2 {
+			small++
+		}
+		if batch := point["onebatch"].(int); batch < 10 || batch > 20 {
+			t.Fatalf("onebatch %v", batch)
+		}
+		if normal := point["normal"]; normal != "l1" && normal != "l2" {
+			t.Fatalf("normal %v", normal)
+		}
+	}
+	// half of a log range lies below 1e-2
+	if small < 400 || small > 600 {
+		t.Errorf("%d of 1000 log samples below 1e-2", small)
+	}
+}
+
+// fakeModel counts the epochs it was trained for.
+type fakeModel struct {
+	epochs int
+}
+
+func TestHalvingPromotesAndResumes(t *testing.T) {
+	rates := []interface{}{0.1, 0.9, 0.5, 0.3, 0.7, 0.2, 0.8, 0.4, 0.6}
+	spec := &Spec{Method: Halving, Metric: metrics.Accuracy, Trials: 9, Workers: 3, Iter: 9, MinIter: 1, Eta: 3,
+		Params: map[string]Param{"learningRate": {Values: rates}}}
+	base := config.TrainConf{FeatureLen: 7, LearningRate: 42}
+
+	var lock sync.Mutex
+	trained := map[int]int{}
+	bestRate := 0.0
+	objective := func(conf config.TrainConf, iter int, model interface{}) (metrics.Result, interface{}) {
+		if conf.FeatureLen != 7 {
+			t.Errorf("override lost feature len %d", conf.FeatureLen)
+		}
+		m, ok := model.(*fakeModel)
+		if !ok {
+			m = &fakeModel{}
+		}
+		m.epochs += iter
+		lock.Lock()
+		trained[iter]++
+		if iter == 1 && conf.LearningRate > bestRate {
+			bestRate = conf.LearningRate
+		}
+		lock.Unlock()
+		// the learning rate of the params is the accuracy
+		return metrics.Result{metrics.Accuracy: conf.LearningRate, "epochs": float64(m.epochs)}, m
+	}
+	spec.Seed = 1
+	best, err := NewSearch(spec, base, objective).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Params["learningRate"] != bestRate || best.Metrics[metrics.Accuracy] != bestRate {
+		t.Errorf("best %v %v", best.Params, best.Metrics)
+	}
+	if best.Rung != 2 || best.Iter != 9 || best.Resumed != 3 || best.Metrics["epochs"] != 9 {
+		t.Errorf("best trial rung %d iter %d resumed %d epochs %v", best.Rung, best.Iter, best.Resumed, best.Metrics["epochs"])
+	}
+	// 9 trials of 1 epoch, the best 3 train 2 more, the best of them 6 more
+	if want := map[int]int{1: 9, 2: 3, 6: 1}; !reflect.DeepEqual(trained, want) {
+		t.Errorf("epochs per objective call %v, want %v", trained, want)
+	}
+}
+
+func TestRunPicksBestOfGrid(t *testing.T) {
+	spec := &Spec{Method: Grid, Metric: metrics.LogLoss, Workers: 2, Iter: 4,
+		Params: map[string]Param{"learningRate": {Values: []interface{}{0.3, 0.1, 0.2}}}}
+	var lock sync.Mutex
+	var seen []float64
+	best, err := NewSearch(spec, config.TrainConf{}, func(conf config.TrainConf, iter int, model interface{}) (metrics.Result, interface{}) {
+		if model != nil || iter != 4 {
+			t.Errorf("grid trial resumes %v with %d epochs", model, iter)
+		}
+		lock.Lock()
+		seen = append(seen, conf.LearningRate)
+		lock.Unlock()
+		return metrics.Result{metrics.LogLoss: conf.LearningRate}, nil
+	}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Float64s(seen)
+	if !reflect.DeepEqual(seen, []float64{0.1, 0.2, 0.3}) || best.Params["learningRate"] != 0.1 {
+		t.Errorf("trained %v, best %v", seen, best.Params)
+	}
+}
+
+func TestLoadSpecRejectsUnusedParams(t *testing.T) {
+	if _, err := LoadSpec("../config/search_lr.yml"); err != nil {
+		t.Errorf("sample spec: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "search.yml")
+	spec := "method: random\nparams:\n  normalRate:\n    min: 0.0001\n    max: 0.1\n"
+	if err := ioutil.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSpec(path); err == nil {
+		t.Error("normalRate accepted, no trainer reads it")
+	}
+}
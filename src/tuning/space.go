@@ -0,0 +1,152 @@
+package tuning
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	Grid     string = "grid"
+	Random   string = "random"
+	Halving  string = "halving"
+	MinIter  int    = 1
+	EtaFloor int    = 2
+)
+
+// Param is either a list of values or a numeric range. Ranges are only
+// usable by random search and successive halving.
+type Param struct {
+	Values []interface{} `yaml:"values"`
+	Min    float64       `yaml:"min"`
+	Max    float64       `yaml:"max"`
+	Log    bool          `yaml:"log"`
+	Int    bool          `yaml:"int"`
+}
+
+// unused are TrainConf keys no trainer reads yet, a search over them would
+// spend its trials on noise and report an arbitrary best value.
+var unused = map[string]bool{"normal": true, "normalRate": true}
+
+// Spec describes a search, params are keyed by their TrainConf yaml name.
+type Spec struct {
+	Model   string           `yaml:"model"`
+	Method  string           `yaml:"method"`
+	Metric  string           `yaml:"metric"`
+	Trials  int              `yaml:"trials"`
+	Workers int              `yaml:"workers"`
+	Iter    int              `yaml:"iter"`
+	MinIter int              `yaml:"minIter"`
+	Eta     int              `yaml:"eta"`
+	Seed    int64            `yaml:"seed"`
+	Results string           `yaml:"results"`
+	Best    string           `yaml:"best"`
+	Params  map[string]Param `yaml:"params"`
+}
+
+func LoadSpec(path string) (*Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	if err = yaml.UnmarshalStrict(data, spec); err != nil {
+		return nil, err
+	}
+	if spec.Method == "" {
+		spec.Method = Grid
+	}
+	if spec.Metric == "" {
+		spec.Metric = "accuracy"
+	}
+	if spec.Workers <= 0 {
+		spec.Workers = 1
+	}
+	if spec.Iter <= 0 {
+		spec.Iter = 10
+	}
+	if spec.MinIter <= 0 {
+		spec.MinIter = MinIter
+	}
+	if spec.Eta < EtaFloor {
+		spec.Eta = 3
+	}
+	if spec.Trials <= 0 {
+		spec.Trials = 10
+	}
+	for name, p := range spec.Params {
+		if unused[name] {
+			return nil, fmt.Errorf("param %s: no trainer reads it", name)
+		}
+		if len(p.Values) == 0 && p.Max < p.Min {
+			return nil, fmt.Errorf("param %s: max %v below min %v", name, p.Max, p.Min)
+		}
+		if len(p.Values) == 0 && p.Log && p.Min <= 0 {
+			return nil, fmt.Errorf("param %s: log range needs min > 0", name)
+		}
+		if len(p.Values) == 0 && spec.Method == Grid {
+			return nil, fmt.Errorf("param %s: grid search needs values", name)
+		}
+	}
+	return spec, nil
+}
+
+func (spec *Spec) names() []string {
+	var names []string
+	for name := range spec.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// grid enumerates the cartesian product of all value lists.
+func (spec *Spec) grid() []map[string]interface{} {
+	result := []map[string]interface{}{{}}
+	for _, name := range spec.names() {
+		var next []map[string]interface{}
+		for _, partial := range result {
+			for _, value := range spec.Params[name].Values {
+				point := make(map[string]interface{}, len(partial)+1)
+				for k, v := range partial {
+					point[k] = v
+				}
+				point[name] = value
+				next = append(next, point)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+func (spec *Spec) sample(r *rand.Rand, n int) []map[string]interface{} {
+	result := make([]map[string]interface{}, n)
+	for i := range result {
+		point := make(map[string]interface{})
+		for _, name := range spec.names() {
+			point[name] = spec.Params[name].sample(r)
+		}
+		result[i] = point
+	}
+	return result
+}
+
+func (p Param) sample(r *rand.Rand) interface{} {
+	if len(p.Values) > 0 {
+		return p.Values[r.Intn(len(p.Values))]
+	}
+	var v float64
+	if p.Log {
+		v = math.Exp(math.Log(p.Min) + r.Float64()*(math.Log(p.Max)-math.Log(p.Min)))
+	} else {
+		v = p.Min + r.Float64()*(p.Max-p.Min)
+	}
+	if p.Int {
+		return int(math.Round(v))
+	}
+	return v
+}
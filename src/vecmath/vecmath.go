@@ -0,0 +1,116 @@
+// Package vecmath holds the dense float64 kernels used by the models. Loops
+// are unrolled by four with independent accumulators, every block is taken
+// as a sub slice so there is one bounds check per four elements.
+package vecmath
+
+// Dot returns sum x[i] * y[i] over the shorter of both.
+func Dot(x, y []float64) float64 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		xs, ys := x[i:i+4:i+4], y[i:i+4:i+4]
+		s0 += xs[0] * ys[0]
+		s1 += xs[1] * ys[1]
+		s2 += xs[2] * ys[2]
+		s3 += xs[3] * ys[3]
+	}
+	for ; i < n; i++ {
+		s0 += x[i] * y[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// Dot32 is Dot for float32 weights, products are summed in float64.
+func Dot32(w []float32, x []float64) float64 {
+	n := len(w)
+	if len(x) < n {
+		n = len(x)
+	}
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		ws, xs := w[i:i+4:i+4], x[i:i+4:i+4]
+		s0 += float64(ws[0]) * xs[0]
+		s1 += float64(ws[1]) * xs[1]
+		s2 += float64(ws[2]) * xs[2]
+		s3 += float64(ws[3]) * xs[3]
+	}
+	for ; i < n; i++ {
+		s0 += float64(w[i]) * x[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// Axpy sets y[i] += alpha * x[i] over the shorter of both.
+func Axpy(alpha float64, x, y []float64) {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		xs, ys := x[i:i+4:i+4], y[i:i+4:i+4]
+		ys[0] += alpha * xs[0]
+		ys[1] += alpha * xs[1]
+		ys[2] += alpha * xs[2]
+		ys[3] += alpha * xs[3]
+	}
+	for ; i < n; i++ {
+		y[i] += alpha * x[i]
+	}
+}
+
+// Axpy32 is Axpy into float32 storage, every sum is formed in float64
+// before rounding.
+func Axpy32(alpha float64, x []float64, y []float32) {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		xs, ys := x[i:i+4:i+4], y[i:i+4:i+4]
+		ys[0] = float32(float64(ys[0]) + alpha*xs[0])
+		ys[1] = float32(float64(ys[1]) + alpha*xs[1])
+		ys[2] = float32(float64(ys[2]) + alpha*xs[2])
+		ys[3] = float32(float64(ys[3]) + alpha*xs[3])
+	}
+	for ; i < n; i++ {
+		y[i] = float32(float64(y[i]) + alpha*x[i])
+	}
+}
+
+// Scale sets x[i] *= alpha.
+func Scale(alpha float64, x []float64) {
+	i := 0
+	for ; i+4 <= len(x); i += 4 {
+		xs := x[i : i+4 : i+4]
+		xs[0] *= alpha
+		xs[1] *= alpha
+		xs[2] *= alpha
+		xs[3] *= alpha
+	}
+	for ; i < len(x); i++ {
+		x[i] *= alpha
+	}
+}
+
+func Sum(x []float64) float64 {
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(x); i += 4 {
+		xs := x[i : i+4 : i+4]
+		s0 += xs[0]
+		s1 += xs[1]
+		s2 += xs[2]
+		s3 += xs[3]
+	}
+	for ; i < len(x); i++ {
+		s0 += x[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
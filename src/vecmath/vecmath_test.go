@@ -0,0 +1,151 @@
+package vecmath
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func randomVector(r *rand.Rand, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = r.NormFloat64()
+	}
+	return x
+}
+
+func naiveDot(x, y []float64) float64 {
+	sum := 0.0
+	for i := range x {
+		sum += x[i] * y[i]
+	}
+	return sum
+}
+
+func TestKernelsMatchNaiveLoops(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	// cover the unrolled body and every tail length
+	for n := 0; n < 12; n++ {
+		x, y := randomVector(r, n), randomVector(r, n)
+		if got, want := Dot(x, y), naiveDot(x, y); math.Abs(got-want) > 1e-12 {
+			t.Errorf("n=%d dot %v, want %v", n, got, want)
+		}
+		w := make([]float32, n)
+		for i := range w {
+			w[i] = float32(y[i])
+		}
+		want := 0.0
+		for i := range x {
+			want += float64(w[i]) * x[i]
+		}
+		if got := Dot32(w, x); math.Abs(got-want) > 1e-12 {
+			t.Errorf("n=%d dot32 %v, want %v", n, got, want)
+		}
+
+		sum := 0.0
+		for _, v := range x {
+			sum += v
+		}
+		if got := Sum(x); math.Abs(got-sum) > 1e-12 {
+			t.Errorf("n=%d sum %v, want %v", n, got, sum)
+		}
+
+		axpy := append([]float64(nil), y...)
+		Axpy(0.5, x, axpy)
+		Axpy32(0.5, x, w)
+		scaled := append([]float64(nil), x...)
+		Scale(3, scaled)
+		for i := range x {
+			if axpy[i] != y[i]+0.5*x[i] {
+				t.Errorf("n=%d axpy[%d] %v", n, i, axpy[i])
+			}
+			if w[i] != float32(float64(float32(y[i]))+0.5*x[i]) {
+				t.Errorf("n=%d axpy32[%d] %v", n, i, w[i])
+			}
+			if scaled[i] != 3*x[i] {
+				t.Errorf("n=%d scale[%d] %v", n, i, scaled[i])
+			}
+		}
+	}
+}
+
+func TestShorterVectorBounds(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6}
+	y := []float64{1, 1, 1}
+	if got := Dot(x, y); got != 6 {
+		t.Errorf("dot %v", got)
+	}
+	Axpy(1, x, y)
+	if y[2] != 4 {
+		t.Errorf("axpy %v", y)
+	}
+}
+
+const benchLen = 784
+
+// sink keeps the compiler from dropping benchmarked results
+var sink float64
+
+func BenchmarkDotNaive(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, benchLen), randomVector(r, benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink += naiveDot(x, y)
+	}
+}
+
+func BenchmarkDot(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, benchLen), randomVector(r, benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink += Dot(x, y)
+	}
+}
+
+func BenchmarkDot32(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x := randomVector(r, benchLen)
+	w := make([]float32, benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink += Dot32(w, x)
+	}
+}
+
+func BenchmarkAxpyNaive(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, benchLen), randomVector(r, benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range x {
+			y[j] += 1e-9 * x[j]
+		}
+	}
+}
+
+func BenchmarkAxpy(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, benchLen), randomVector(r, benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Axpy(1e-9, x, y)
+	}
+}
+
+func BenchmarkScale(b *testing.B) {
+	x := randomVector(rand.New(rand.NewSource(1)), benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Scale(1, x)
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	x := randomVector(rand.New(rand.NewSource(1)), benchLen)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink += Sum(x)
+	}
+}